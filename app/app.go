@@ -2,15 +2,28 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/aplr/lacuna/docker"
+	"github.com/aplr/lacuna/filter"
+	"github.com/aplr/lacuna/proxy"
 	"github.com/aplr/lacuna/pubsub"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	labelPrefix = "lacuna"
+
+	// Events which attach the push endpoints of subscriptions
+	attachingEvents = map[docker.EventType]bool{
+		docker.EVENT_TYPE_START:   true,
+		docker.EVENT_TYPE_NETWORK: true,
+		docker.EVENT_TYPE_HEALTHY: true,
+		docker.EVENT_TYPE_UNPAUSE: true,
+	}
 )
 
 type App struct {
@@ -18,6 +31,9 @@ type App struct {
 	config *Config
 	docker docker.Docker
 	pubsub pubsub.PubSub
+	proxy  proxy.Proxy
+	topics *topicReferences
+	labels *expandedLabels
 }
 
 func NewApp(docker docker.Docker, pubsub pubsub.PubSub) (*App, error) {
@@ -34,6 +50,8 @@ func NewApp(docker docker.Docker, pubsub pubsub.PubSub) (*App, error) {
 		config: config,
 		docker: docker,
 		pubsub: pubsub,
+		topics: newTopicReferences(),
+		labels: newExpandedLabels(),
 	}, nil
 }
 
@@ -41,13 +59,13 @@ func NewDefaultApp(ctx context.Context) (*App, error) {
 	app, err := NewApp(nil, nil)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	docker, err := docker.NewDocker(app.config.LabelPrefix)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error creating docker client: %w", err)
 	}
 
 	app.docker = docker
@@ -55,10 +73,11 @@ func NewDefaultApp(ctx context.Context) (*App, error) {
 	pubsub, err := pubsub.NewPubSub(ctx, app.config.PubSub)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("error creating pubsub client: %w", err)
 	}
 
 	app.pubsub = pubsub
+	app.proxy = proxy.NewProxy(app.config.Proxy, pubsub)
 
 	return app, nil
 }
@@ -69,6 +88,42 @@ func (app *App) Run(ctx context.Context) error {
 
 	events, errs := app.docker.Run(ctx)
 
+	// events are queued until pub/sub is ready, instead of failing while
+	// the emulator is still starting up
+	queued, err := app.waitForPubSub(ctx, events, errs)
+
+	if err != nil {
+		// stopped while waiting
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	// clean up before handling events, so subscriptions of
+	// containers started in the meantime are not considered orphaned
+	if err := app.deleteOrphanedSubscriptions(ctx); err != nil {
+		// don't propagate errors, just log them
+		app.log.WithError(err).Error("failed to remove orphaned subscriptions")
+	}
+
+	proxyErrs := make(chan error, 1)
+
+	// the proxy relays push requests of subscriptions opting in
+	if app.proxy != nil {
+		go func() {
+			if err := app.proxy.Run(ctx); err != nil {
+				proxyErrs <- err
+			}
+		}()
+	}
+
+	// queued events are handled in order before any live event, as they
+	// may refer to the same container starting and stopping
+	for _, evt := range queued {
+		app.handleContainerEvent(ctx, evt)
+	}
+
 out:
 	for {
 		select {
@@ -76,6 +131,8 @@ out:
 			break out
 		case err := <-errs:
 			return err
+		case err := <-proxyErrs:
+			return err
 		case evt := <-events:
 			go app.handleContainerEvent(ctx, evt)
 		}
@@ -84,39 +141,321 @@ out:
 	return nil
 }
 
+// waitForPubSub probes pub/sub until it answers, backing off between probes,
+// and returns the container events received in the meantime. An error is
+// returned if pub/sub does not answer within the configured timeout.
+func (app *App) waitForPubSub(ctx context.Context, events <-chan docker.Event, errs <-chan error) ([]docker.Event, error) {
+	config := app.config.PubSub
+
+	host := os.Getenv("PUBSUB_EMULATOR_HOST")
+
+	if host == "" {
+		host = "pub/sub"
+	}
+
+	log := app.log.WithField("host", host).WithField("project", config.ProjectID)
+
+	if config.ReadyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ReadyTimeout)
+		defer cancel()
+	}
+
+	queued := make([]docker.Event, 0)
+	backoff := config.ReadyBackoff
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := app.pubsub.Ping(pingCtx)
+		cancel()
+
+		if err == nil {
+			log.WithField("attempts", attempt).Info("pub/sub ready")
+			return queued, nil
+		}
+
+		log.WithError(err).WithField("attempt", attempt).WithField("retry_in", backoff).Infof("waiting for pub/sub at %s to become ready", host)
+
+		timer := time.NewTimer(backoff)
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return nil, fmt.Errorf("pub/sub at %s not ready after %s: %w", host, config.ReadyTimeout, err)
+				}
+				return nil, ctx.Err()
+			case err := <-errs:
+				timer.Stop()
+				return nil, err
+			case evt, ok := <-events:
+				if !ok {
+					// closed once docker stopped, which is reported on errs
+					events = nil
+					continue
+				}
+				queued = append(queued, evt)
+			case <-timer.C:
+				break wait
+			}
+		}
+
+		if backoff *= 2; backoff > config.ReadyMaxBackoff {
+			backoff = config.ReadyMaxBackoff
+		}
+	}
+}
+
+// deleteOrphanedSubscriptions deletes subscriptions of containers which
+// stopped while lacuna was not running, and thus were never removed.
+func (app *App) deleteOrphanedSubscriptions(ctx context.Context) error {
+	containers, err := app.docker.Containers(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	containerIDs := make([]string, 0, len(containers))
+
+	for _, container := range containers {
+		containerIDs = append(containerIDs, container.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return app.pubsub.DeleteOrphanedSubscriptions(ctx, containerIDs)
+}
+
 func (app *App) handleContainerEvent(ctx context.Context, evt docker.Event) {
 	log := app.log.WithField("event_type", evt.Type).WithField("container", evt.Container.Name())
 
+	switch evt.Type {
+	case docker.EVENT_TYPE_NETWORK:
+		app.handleNetworkEvent(ctx, evt)
+		return
+	case docker.EVENT_TYPE_HEALTHY, docker.EVENT_TYPE_UNHEALTHY, docker.EVENT_TYPE_PAUSE, docker.EVENT_TYPE_UNPAUSE:
+		app.handleAvailabilityEvent(ctx, evt)
+		return
+	case docker.EVENT_TYPE_START:
+		evt.Container = app.labels.expand(evt.Container)
+	case docker.EVENT_TYPE_STOP:
+		evt.Container = app.labels.release(evt.Container)
+	}
+
+	topics := extractTopics(evt.Container)
 	subscriptions := extractSubscriptions(evt.Container)
+	chaos := extractChaos(evt.Container)
 
-	if (len(subscriptions)) == 0 {
-		log.Warn("no subscriptions found")
+	if len(topics) == 0 && len(subscriptions) == 0 {
+		log.Warn("no subscriptions or topics found")
 		return
 	}
 
+	log.Debugf("processing %d topics", len(topics))
+
+	// topics are processed first, so subscriptions can
+	// subscribe to topics declared by the same container
+	for _, topic := range topics {
+		if err := app.processTopic(ctx, topic, evt); err != nil {
+			// don't propagate errors, just log them
+			log.WithError(err).Error("failed to process topic")
+		}
+	}
+
 	log.Debugf("processing %d subscriptions", len(subscriptions))
 
+	// endpoints of containers which are not healthy yet, or
+	// paused, are attached once the container is available
+	pending := evt.Type == docker.EVENT_TYPE_START && !isAvailable(evt.Container)
+
 	for _, subscription := range subscriptions {
-		if err := app.processSubscription(ctx, subscription, evt); err != nil {
+		if pending && deliversToContainer(subscription) {
+			log.WithField("subscription", subscription.Name).Info("waiting for container to become available")
+			continue
+		}
+
+		if err := app.processSubscription(ctx, subscription, chaos[subscription.Name], evt); err != nil {
+			// don't propagate errors, just log them
+			log.WithError(err).Error("failed to process subscription")
+		}
+	}
+
+	references := referencedTopics(evt.Container, topics, subscriptions, app.config.PubSub.ProjectID)
+
+	switch evt.Type {
+	case docker.EVENT_TYPE_START:
+		for topic, retain := range references {
+			app.topics.acquire(topic, evt.Container.ID, retain)
+		}
+	case docker.EVENT_TYPE_STOP:
+		for topic := range references {
+			if err := app.releaseTopic(ctx, topic, evt); err != nil {
+				// don't propagate errors, just log them
+				log.WithError(err).Error("failed to release topic")
+			}
+		}
+	}
+}
+
+// handleNetworkEvent updates the subscriptions whose endpoint is resolved
+// from the networks of the container, once it was connected to or
+// disconnected from a network.
+func (app *App) handleNetworkEvent(ctx context.Context, evt docker.Event) {
+	log := app.log.WithField("event_type", evt.Type).WithField("container", evt.Container.Name())
+
+	// endpoints of unavailable containers stay detached
+	if !isAvailable(evt.Container) {
+		return
+	}
+
+	// templates may refer to the networks of the container
+	evt.Container = app.labels.expand(evt.Container)
+
+	chaos := extractChaos(evt.Container)
+
+	for _, subscription := range extractSubscriptions(evt.Container) {
+		if !subscription.HasEndpointPath() {
+			continue
+		}
+
+		if err := app.processSubscription(ctx, subscription, chaos[subscription.Name], evt); err != nil {
+			// don't propagate errors, just log them
+			log.WithError(err).Error("failed to process subscription")
+		}
+	}
+}
+
+// handleAvailabilityEvent attaches the endpoints of push subscriptions once
+// the container becomes healthy or is unpaused, and detaches them once it
+// becomes unhealthy or is paused, so messages accumulate in the meantime.
+func (app *App) handleAvailabilityEvent(ctx context.Context, evt docker.Event) {
+	log := app.log.WithField("event_type", evt.Type).WithField("container", evt.Container.Name())
+
+	switch evt.Type {
+	case docker.EVENT_TYPE_HEALTHY, docker.EVENT_TYPE_UNHEALTHY:
+		if !waitsForHealthy(evt.Container) {
+			return
+		}
+	case docker.EVENT_TYPE_UNPAUSE:
+		// endpoints of unhealthy containers are attached once they are healthy
+		if !isAvailable(evt.Container) {
+			return
+		}
+	}
+
+	evt.Container = app.labels.expand(evt.Container)
+
+	chaos := extractChaos(evt.Container)
+
+	for _, subscription := range extractSubscriptions(evt.Container) {
+		if !deliversToContainer(subscription) {
+			continue
+		}
+
+		if err := app.processSubscription(ctx, subscription, chaos[subscription.Name], evt); err != nil {
 			// don't propagate errors, just log them
 			log.WithError(err).Error("failed to process subscription")
 		}
 	}
 }
 
-func (app *App) processSubscription(ctx context.Context, subscription pubsub.Subscription, evt docker.Event) error {
+// releaseTopic removes the container's reference to the topic,
+// and deletes the topic if it is no longer referenced.
+func (app *App) releaseTopic(ctx context.Context, topic string, evt docker.Event) error {
+	log := app.log.WithField("container", evt.Container.Name()).WithField("topic", topic)
+
+	if !app.topics.release(topic, evt.Container.ID) || app.config.TopicPolicy == TOPIC_POLICY_RETAIN {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5000*time.Millisecond)
+	defer cancel()
+
+	if err := app.pubsub.DeleteTopic(ctx, pubsub.Topic{Name: topic}); err != nil {
+		return err
+	}
+
+	log.Info("unreferenced topic removed")
+
+	return nil
+}
+
+func (app *App) processTopic(ctx context.Context, topic pubsub.Topic, evt docker.Event) error {
+	log := app.log.WithField("container", evt.Container.Name()).WithField("topic", topic.Name)
+
+	ctx, cancel := context.WithTimeout(ctx, 5000*time.Millisecond)
+	defer cancel()
+
+	switch evt.Type {
+	case docker.EVENT_TYPE_START:
+		if err := app.pubsub.CreateTopic(ctx, topic); err != nil {
+			return err
+		}
+		log.Info("topic created")
+	}
+
+	return nil
+}
+
+func (app *App) processSubscription(ctx context.Context, subscription pubsub.Subscription, chaos proxy.Chaos, evt docker.Event) error {
 	log := app.log.WithField("container", evt.Container.Name()).WithField("subscription", subscription.Name).WithField("topic", subscription.Topic)
 
 	ctx, cancel := context.WithTimeout(ctx, 5000*time.Millisecond)
 	defer cancel()
 
+	if subscription.HasEndpointPath() && attachingEvents[evt.Type] {
+		endpoint, err := app.resolveEndpoint(subscription, evt.Container)
+
+		if err != nil {
+			return err
+		}
+
+		subscription.Endpoint = endpoint
+		log = log.WithField("endpoint", endpoint)
+	}
+
+	route, proxied := app.proxyRoute(subscription, chaos, evt)
+
+	if subscription.IsCapture() && !proxied {
+		return fmt.Errorf("capture sink %s requires the proxy", subscription.Name)
+	}
+
 	switch evt.Type {
 	case docker.EVENT_TYPE_START:
+		if proxied {
+			subscription.ProxyEndpoint = app.proxy.Register(route)
+		}
 		if err := app.pubsub.CreateSubscription(ctx, subscription); err != nil {
 			return err
 		}
 		log.Info("subscription created")
+	case docker.EVENT_TYPE_NETWORK, docker.EVENT_TYPE_HEALTHY, docker.EVENT_TYPE_UNPAUSE:
+		if proxied {
+			subscription.ProxyEndpoint = app.proxy.Register(route)
+		}
+		if err := app.pubsub.CreateSubscription(ctx, subscription); err != nil {
+			return err
+		}
+		log.Info("subscription endpoint updated")
+	case docker.EVENT_TYPE_UNHEALTHY, docker.EVENT_TYPE_PAUSE:
+		if err := app.pubsub.SuspendSubscription(ctx, subscription); err != nil {
+			return err
+		}
+		log.Info("subscription endpoint detached")
 	case docker.EVENT_TYPE_STOP:
+		if proxied {
+			defer app.proxy.Unregister(route)
+		}
+		if subscription.KeepBacklog {
+			if err := app.pubsub.SuspendSubscription(ctx, subscription); err != nil {
+				return err
+			}
+			log.Info("subscription suspended")
+			return nil
+		}
 		if err := app.pubsub.DeleteSubscription(ctx, subscription); err != nil {
 			return err
 		}
@@ -125,3 +464,111 @@ func (app *App) processSubscription(ctx context.Context, subscription pubsub.Sub
 
 	return nil
 }
+
+// resolveEndpoint returns the endpoint of a subscription whose endpoint is
+// a path, using the address of the container in its network, or the port it
+// publishes on the host in host mode.
+func (app *App) resolveEndpoint(subscription pubsub.Subscription, container docker.Container) (string, error) {
+	switch app.config.EndpointMode {
+	case ENDPOINT_MODE_HOST:
+		port, err := container.PublishedPort(subscription.EndpointPort)
+
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("http://%s:%d%s", app.config.EndpointHost, port, subscription.Endpoint), nil
+	case ENDPOINT_MODE_NETWORK:
+		address, err := container.NetworkAddress(subscription.EndpointNetwork, subscription.EndpointPort)
+
+		if err != nil {
+			return "", err
+		}
+
+		return "http://" + address + subscription.Endpoint, nil
+	default:
+		return "", fmt.Errorf("invalid endpoint mode: %s, must be one of 'network' or 'host'", app.config.EndpointMode)
+	}
+}
+
+// proxyRoute returns the route relaying push requests of the subscription to
+// its endpoint, and false if the subscription is not relayed through the proxy.
+// Subscriptions with chaos options or delivery policies are always relayed,
+// which applies them.
+func (app *App) proxyRoute(subscription pubsub.Subscription, chaos proxy.Chaos, evt docker.Event) (proxy.Route, bool) {
+	if app.proxy == nil || !subscription.IsPush() {
+		return proxy.Route{}, false
+	}
+
+	// messages of capture sinks are stored by the proxy
+	if subscription.IsCapture() {
+		return proxy.Route{
+			Container:    evt.Container.Name(),
+			Project:      subscription.GetProject(app.config.PubSub.ProjectID),
+			Subscription: subscription.GetSubscriptionID(),
+			Filter:       parseFilter(subscription),
+			Capture:      true,
+		}, true
+	}
+
+	delivery := app.deliveryPolicy(subscription)
+
+	if !subscription.Proxy && !chaos.Enabled() && delivery == nil && !app.config.Proxy.Enabled {
+		return proxy.Route{}, false
+	}
+
+	return proxy.Route{
+		Container:    evt.Container.Name(),
+		Project:      subscription.GetProject(app.config.PubSub.ProjectID),
+		Subscription: subscription.GetSubscriptionID(),
+		Endpoint:     subscription.Endpoint,
+		Chaos:        chaos,
+		Delivery:     delivery,
+		Filter:       parseFilter(subscription),
+	}, true
+}
+
+// parseFilter returns the parsed filter of the subscription, or nil if it has
+// none. Filters were validated during label extraction already.
+func parseFilter(subscription pubsub.Subscription) filter.Filter {
+	if subscription.Filter == "" {
+		return nil
+	}
+
+	f, _ := filter.Parse(subscription.Filter)
+
+	return f
+}
+
+// deliveryPolicy returns the dead-letter and retry policy of the subscription
+// enforced by the proxy, or nil if the subscription has neither.
+func (app *App) deliveryPolicy(subscription pubsub.Subscription) *proxy.DeliveryPolicy {
+	if !app.config.Proxy.Delivery {
+		return nil
+	}
+
+	retry := subscription.RetryMinimumBackoff != nil || subscription.RetryMaximumBackoff != nil
+
+	if subscription.DeadLetterTopic == "" && !retry {
+		return nil
+	}
+
+	policy := &proxy.DeliveryPolicy{
+		MaxDeliveryAttempts: subscription.MaxDeadLetterDeliveryAttempts,
+	}
+
+	if subscription.DeadLetterTopic != "" {
+		topic := subscription.GetDeadLetterTopic()
+		policy.DeadLetterTopic = topic.QualifiedName(subscription.GetProject(app.config.PubSub.ProjectID))
+	}
+
+	if subscription.RetryMinimumBackoff != nil {
+		policy.MinimumBackoff = *subscription.RetryMinimumBackoff
+	}
+
+	if subscription.RetryMaximumBackoff != nil {
+		policy.MaximumBackoff = *subscription.RetryMaximumBackoff
+	}
+
+	return policy
+}
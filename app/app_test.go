@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/aplr/lacuna/docker"
+	"github.com/aplr/lacuna/proxy"
 	"github.com/aplr/lacuna/pubsub"
 )
 
@@ -51,8 +52,18 @@ func TestRunClosesOnContextCancel(t *testing.T) {
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return make(chan docker.Event), make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	pubsub := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 	}
-	pubsub := &mockPubSub{}
 
 	app, err := NewApp(docker, pubsub)
 
@@ -82,8 +93,18 @@ func TestRunPropagatesErrorFromDocker(t *testing.T) {
 			}()
 			return nil, errs
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	pubsub := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 	}
-	pubsub := &mockPubSub{}
 
 	app, err := NewApp(docker, pubsub)
 
@@ -100,16 +121,30 @@ func TestRunPropagatesErrorFromDocker(t *testing.T) {
 	}
 }
 
-func TestRunHandlesContainerStartEvent(t *testing.T) {
+func TestRunQueuesEventsUntilPubSubIsReady(t *testing.T) {
 	// arrange
 	events := make(chan docker.Event)
 	subscriptions := make(chan pubsub.Subscription)
+	pings := make(chan struct{}, 3)
 	d := &mockDocker{
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return events, make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
 	}
 	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			pings <- struct{}{}
+			if len(pings) < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
 			subscriptions <- subscription
 			return nil
@@ -122,6 +157,9 @@ func TestRunHandlesContainerStartEvent(t *testing.T) {
 		t.Errorf("Expected err to be nil, got %v", err)
 	}
 
+	app.config.PubSub.ReadyBackoff = 20 * time.Millisecond
+	app.config.PubSub.ReadyMaxBackoff = 20 * time.Millisecond
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -136,7 +174,7 @@ func TestRunHandlesContainerStartEvent(t *testing.T) {
 		Type: docker.EVENT_TYPE_START,
 		Container: docker.NewContainer("1", map[string]string{
 			"lacuna.subscription.test.topic":    "test",
-			"lacuna.subscription.test.endpoint": "/messages",
+			"lacuna.subscription.test.endpoint": "http://test/messages",
 		}),
 	}
 
@@ -145,23 +183,147 @@ func TestRunHandlesContainerStartEvent(t *testing.T) {
 	case <-ctx.Done():
 		t.Errorf("Expected context to not be done")
 	case subscription := <-subscriptions:
-		if subscription.Topic != "test" {
-			t.Errorf("Expected topic to be 'test', got %v", subscription.Topic)
+		if len(pings) != 3 {
+			t.Errorf("Expected subscription to be created once pub/sub is ready, got %d pings", len(pings))
+		}
+		if subscription.Name != "test" {
+			t.Errorf("Expected subscription name to be 'test', got %v", subscription.Name)
 		}
 	}
 }
 
-func TestRunHandlesContainerStopEvent(t *testing.T) {
+func TestRunHandlesQueuedEventsBeforeLiveEvents(t *testing.T) {
 	// arrange
 	events := make(chan docker.Event)
-	subscriptions := make(chan pubsub.Subscription)
+	ready := make(chan struct{})
+	calls := make(chan string, 2)
+	pings := 0
 	d := &mockDocker{
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return events, make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
 	}
 	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			if pings++; pings < 2 {
+				return errors.New("connection refused")
+			}
+			close(ready)
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			// slow enough for a concurrently handled stop to overtake it
+			time.Sleep(50 * time.Millisecond)
+			calls <- "create"
+			return nil
+		},
 		deleteSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			calls <- "delete"
+			return nil
+		},
+		deleteTopic: func(ctx context.Context, topic pubsub.Topic) error {
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	app.config.PubSub.ReadyBackoff = 20 * time.Millisecond
+	app.config.PubSub.ReadyMaxBackoff = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	labels := map[string]string{
+		"lacuna.subscription.test.topic":    "test",
+		"lacuna.subscription.test.endpoint": "http://test/messages",
+	}
+
+	// act
+	events <- docker.Event{Type: docker.EVENT_TYPE_START, Container: docker.NewContainer("1", labels)}
+	<-ready
+	events <- docker.Event{Type: docker.EVENT_TYPE_STOP, Container: docker.NewContainer("1", labels)}
+
+	// assert
+	for _, expected := range []string{"create", "delete"} {
+		select {
+		case <-time.After(time.Second):
+			t.Fatalf("Expected subscription to be %sd", expected)
+		case call := <-calls:
+			if call != expected {
+				t.Errorf("Expected queued start to be handled before live stop, got %s first", call)
+			}
+		}
+	}
+}
+
+func TestRunFailsIfPubSubIsNotReadyInTime(t *testing.T) {
+	// arrange
+	docker := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return make(chan docker.Event), make(chan error, 1)
+		},
+	}
+	pubsub := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		},
+	}
+
+	app, err := NewApp(docker, pubsub)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	app.config.PubSub.ReadyTimeout = 50 * time.Millisecond
+	app.config.PubSub.ReadyBackoff = 10 * time.Millisecond
+
+	// act
+	err = app.Run(context.Background())
+
+	// assert
+	if err == nil {
+		t.Errorf("Expected error to be non-nil, got %v", err)
+	}
+}
+
+func TestRunHandlesContainerStartEvent(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
 			subscriptions <- subscription
 			return nil
 		},
@@ -184,10 +346,10 @@ func TestRunHandlesContainerStopEvent(t *testing.T) {
 
 	// act
 	events <- docker.Event{
-		Type: docker.EVENT_TYPE_STOP,
+		Type: docker.EVENT_TYPE_START,
 		Container: docker.NewContainer("1", map[string]string{
 			"lacuna.subscription.test.topic":    "test",
-			"lacuna.subscription.test.endpoint": "/messages",
+			"lacuna.subscription.test.endpoint": "http://test/messages",
 		}),
 	}
 
@@ -202,7 +364,7 @@ func TestRunHandlesContainerStopEvent(t *testing.T) {
 	}
 }
 
-func TestRunHandlesNoSubscriptions(t *testing.T) {
+func TestRunHandlesContainerStopEvent(t *testing.T) {
 	// arrange
 	events := make(chan docker.Event)
 	subscriptions := make(chan pubsub.Subscription)
@@ -210,9 +372,18 @@ func TestRunHandlesNoSubscriptions(t *testing.T) {
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return events, make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
 	}
 	p := &mockPubSub{
-		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		deleteSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
 			subscriptions <- subscription
 			return nil
 		},
@@ -224,7 +395,7 @@ func TestRunHandlesNoSubscriptions(t *testing.T) {
 		t.Errorf("Expected err to be nil, got %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	go func() {
@@ -235,31 +406,46 @@ func TestRunHandlesNoSubscriptions(t *testing.T) {
 
 	// act
 	events <- docker.Event{
-		Type:      docker.EVENT_TYPE_START,
-		Container: docker.NewContainer("1", map[string]string{}),
+		Type: docker.EVENT_TYPE_STOP,
+		Container: docker.NewContainer("1", map[string]string{
+			"lacuna.subscription.test.topic":    "test",
+			"lacuna.subscription.test.endpoint": "/messages",
+		}),
 	}
 
 	// assert
 	select {
 	case <-ctx.Done():
-		// we expect a timeout to happen, as no events or errors should be sent
-		return
-	case <-subscriptions:
-		t.Errorf("Expected no subscriptions to be created")
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Topic != "test" {
+			t.Errorf("Expected topic to be 'test', got %v", subscription.Topic)
+		}
 	}
 }
 
-func TestRunHandlesCreateSubascriptionError(t *testing.T) {
+func TestRunHandlesContainerStartEventWithTopic(t *testing.T) {
 	// arrange
 	events := make(chan docker.Event)
+	topics := make(chan pubsub.Topic)
 	d := &mockDocker{
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return events, make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
 	}
 	p := &mockPubSub{
-		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
-			return errors.New("create subscription failed")
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createTopic: func(ctx context.Context, topic pubsub.Topic) error {
+			topics <- topic
+			return nil
 		},
 	}
 
@@ -269,7 +455,7 @@ func TestRunHandlesCreateSubascriptionError(t *testing.T) {
 		t.Errorf("Expected err to be nil, got %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	go func() {
@@ -282,26 +468,49 @@ func TestRunHandlesCreateSubascriptionError(t *testing.T) {
 	events <- docker.Event{
 		Type: docker.EVENT_TYPE_START,
 		Container: docker.NewContainer("1", map[string]string{
-			"lacuna.subscription.test.topic":    "test",
-			"lacuna.subscription.test.endpoint": "/messages",
+			"lacuna.topic.test.retention-duration": "1h",
 		}),
 	}
 
 	// assert
-	<-ctx.Done()
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case topic := <-topics:
+		if topic.Name != "test" {
+			t.Errorf("Expected topic to be 'test', got %v", topic.Name)
+		}
+	}
 }
 
-func TestRunHandlesDeleteSubascriptionError(t *testing.T) {
+func TestRunDeletesUnreferencedTopicOnStopEvent(t *testing.T) {
 	// arrange
 	events := make(chan docker.Event)
+	topics := make(chan pubsub.Topic)
 	d := &mockDocker{
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return events, make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
 	}
 	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			return nil
+		},
 		deleteSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
-			return errors.New("delete subscription failed")
+			return nil
+		},
+		deleteTopic: func(ctx context.Context, topic pubsub.Topic) error {
+			topics <- topic
+			return nil
 		},
 	}
 
@@ -311,7 +520,70 @@ func TestRunHandlesDeleteSubascriptionError(t *testing.T) {
 		t.Errorf("Expected err to be nil, got %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":    "test",
+		"lacuna.subscription.test.endpoint": "http://test/messages",
+	})
+
+	// handle the start event synchronously, so the reference
+	// is acquired before the stop event is processed
+	app.handleContainerEvent(ctx, docker.Event{Type: docker.EVENT_TYPE_START, Container: container})
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{Type: docker.EVENT_TYPE_STOP, Container: container}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case topic := <-topics:
+		if topic.Name != "projects/pubsub/topics/test" {
+			t.Errorf("Expected topic to be 'projects/pubsub/topics/test', got %v", topic.Name)
+		}
+	}
+}
+
+func TestRunSuspendsSubscriptionKeepingBacklogOnStopEvent(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		suspendSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	go func() {
@@ -324,11 +596,813 @@ func TestRunHandlesDeleteSubascriptionError(t *testing.T) {
 	events <- docker.Event{
 		Type: docker.EVENT_TYPE_STOP,
 		Container: docker.NewContainer("1", map[string]string{
-			"lacuna.subscription.test.topic":    "test",
-			"lacuna.subscription.test.endpoint": "/messages",
+			"lacuna.subscription.test.topic":        "test",
+			"lacuna.subscription.test.endpoint":     "/messages",
+			"lacuna.subscription.test.keep-backlog": "true",
 		}),
 	}
 
 	// assert
-	<-ctx.Done()
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if !subscription.KeepBacklog {
+			t.Errorf("Expected subscription to keep its backlog")
+		}
+	}
+}
+
+func TestRunRelaysProxiedSubscriptionThroughProxy(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	app.proxy = &mockProxy{
+		run: func(ctx context.Context) error {
+			return nil
+		},
+		register: func(route proxy.Route) string {
+			return "http://lacuna:8090/" + route.Subscription
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type: docker.EVENT_TYPE_START,
+		Container: docker.NewContainer("1", map[string]string{
+			"lacuna.subscription.test.topic":    "test",
+			"lacuna.subscription.test.endpoint": "http://test/messages",
+			"lacuna.subscription.test.proxy":    "true",
+		}),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.ProxyEndpoint != "http://lacuna:8090/1_test" {
+			t.Errorf("Expected proxy endpoint to be 'http://lacuna:8090/1_test', got %v", subscription.ProxyEndpoint)
+		}
+		if subscription.Endpoint != "http://test/messages" {
+			t.Errorf("Expected endpoint to be 'http://test/messages', got %v", subscription.Endpoint)
+		}
+	}
+}
+
+func TestRunRegistersCaptureSinkWithProxy(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	routes := make(chan proxy.Route)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	app.proxy = &mockProxy{
+		run: func(ctx context.Context) error {
+			return nil
+		},
+		register: func(route proxy.Route) string {
+			routes <- route
+			return "http://lacuna:8090/" + route.Subscription
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type: docker.EVENT_TYPE_START,
+		Container: docker.NewContainer("1", map[string]string{
+			"lacuna.subscription.test.topic":    "test",
+			"lacuna.subscription.test.endpoint": "lacuna://capture",
+		}),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case route := <-routes:
+		if !route.Capture {
+			t.Errorf("Expected route to capture messages")
+		}
+		if route.Subscription != "1_test" {
+			t.Errorf("Expected subscription to be '1_test', got %v", route.Subscription)
+		}
+	}
+}
+
+func networkedContainer() docker.Container {
+	container := docker.NewContainer("1", map[string]string{
+		"com.docker.compose.project":         "project",
+		"com.docker.compose.service":         "api",
+		"lacuna.subscription.test.topic":     "test",
+		"lacuna.subscription.test.endpoint":  "/messages",
+		"lacuna.subscription.test.port":      "8080",
+		"lacuna.subscription.test.network":   "backend",
+		"lacuna.subscription.other.topic":    "test",
+		"lacuna.subscription.other.endpoint": "http://other/messages",
+	})
+	container.Networks = map[string]docker.Network{
+		"project_backend": {Name: "project_backend", IP: "172.18.0.2", Aliases: []string{"api"}},
+	}
+	container.Ports = []docker.Port{
+		{Port: 8080, Protocol: "tcp", HostPorts: []int{32768}},
+	}
+
+	return container
+}
+
+func TestRunResolvesEndpointPathOnStartEvent(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			if subscription.Name == "test" {
+				subscriptions <- subscription
+			}
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_START,
+		Container: networkedContainer(),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Endpoint != "http://api:8080/messages" {
+			t.Errorf("Expected endpoint to be 'http://api:8080/messages', got %v", subscription.Endpoint)
+		}
+	}
+}
+
+func TestRunUpdatesEndpointPathsOnNetworkEvent(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_NETWORK,
+		Container: networkedContainer(),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "test" {
+			t.Errorf("Expected only subscription 'test' to be updated, got %v", subscription.Name)
+		}
+		if subscription.Endpoint != "http://api:8080/messages" {
+			t.Errorf("Expected endpoint to be 'http://api:8080/messages', got %v", subscription.Endpoint)
+		}
+	}
+
+	select {
+	case subscription := <-subscriptions:
+		t.Errorf("Expected subscription %v not to be updated", subscription.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func healthcheckedContainer(health docker.HealthStatus) docker.Container {
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.push.topic":    "test",
+		"lacuna.subscription.push.endpoint": "http://test/messages",
+		"lacuna.subscription.pull.topic":    "test",
+		"lacuna.subscription.pull.type":     "pull",
+	})
+	container.Health = health
+
+	return container
+}
+
+func TestRunWaitsForContainerToBecomeHealthy(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_START,
+		Container: healthcheckedContainer(docker.HEALTH_STATUS_STARTING),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "pull" {
+			t.Errorf("Expected only pull subscription to be created, got %v", subscription.Name)
+		}
+	}
+
+	select {
+	case subscription := <-subscriptions:
+		t.Errorf("Expected subscription %v not to be created before the container is healthy", subscription.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_HEALTHY,
+		Container: healthcheckedContainer(docker.HEALTH_STATUS_HEALTHY),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "push" {
+			t.Errorf("Expected push subscription to be created, got %v", subscription.Name)
+		}
+	}
+}
+
+func TestRunDetachesEndpointOfUnhealthyContainer(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		suspendSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_UNHEALTHY,
+		Container: healthcheckedContainer(docker.HEALTH_STATUS_UNHEALTHY),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "push" {
+			t.Errorf("Expected only push subscription to be detached, got %v", subscription.Name)
+		}
+	}
+
+	select {
+	case subscription := <-subscriptions:
+		t.Errorf("Expected subscription %v not to be detached", subscription.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunDetachesEndpointOfPausedContainer(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		suspendSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	container := healthcheckedContainer(docker.HEALTH_STATUS_NONE)
+	container.Paused = true
+
+	// act
+	events <- docker.Event{Type: docker.EVENT_TYPE_PAUSE, Container: container}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "push" {
+			t.Errorf("Expected only push subscription to be detached, got %v", subscription.Name)
+		}
+	}
+}
+
+func TestRunRestoresEndpointOfUnpausedContainer(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription, 2)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_UNPAUSE,
+		Container: healthcheckedContainer(docker.HEALTH_STATUS_UNHEALTHY),
+	}
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_UNPAUSE,
+		Container: healthcheckedContainer(docker.HEALTH_STATUS_HEALTHY),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		t.Errorf("Expected context to not be done")
+	case subscription := <-subscriptions:
+		if subscription.Name != "push" {
+			t.Errorf("Expected push subscription to be restored, got %v", subscription.Name)
+		}
+	}
+
+	select {
+	case subscription := <-subscriptions:
+		t.Errorf("Expected endpoint of unhealthy container not to be restored, got %v", subscription.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestResolveEndpointUsesPublishedPortInHostMode(t *testing.T) {
+	// arrange
+	app, err := NewApp(nil, nil)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	app.config.EndpointMode = ENDPOINT_MODE_HOST
+	app.config.EndpointHost = "localhost"
+
+	subscription := pubsub.Subscription{Endpoint: "/messages", EndpointPort: 8080}
+
+	// act
+	endpoint, err := app.resolveEndpoint(subscription, networkedContainer())
+
+	// assert
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	if endpoint != "http://localhost:32768/messages" {
+		t.Errorf("Expected endpoint to be 'http://localhost:32768/messages', got %v", endpoint)
+	}
+}
+
+func TestRunHandlesNoSubscriptions(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	subscriptions := make(chan pubsub.Subscription)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			subscriptions <- subscription
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type:      docker.EVENT_TYPE_START,
+		Container: docker.NewContainer("1", map[string]string{}),
+	}
+
+	// assert
+	select {
+	case <-ctx.Done():
+		// we expect a timeout to happen, as no events or errors should be sent
+		return
+	case <-subscriptions:
+		t.Errorf("Expected no subscriptions to be created")
+	}
+}
+
+func TestRunHandlesCreateSubascriptionError(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		createSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			return errors.New("create subscription failed")
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type: docker.EVENT_TYPE_START,
+		Container: docker.NewContainer("1", map[string]string{
+			"lacuna.subscription.test.topic":    "test",
+			"lacuna.subscription.test.endpoint": "http://test/messages",
+		}),
+	}
+
+	// assert
+	<-ctx.Done()
+}
+
+func TestRunHandlesDeleteSubascriptionError(t *testing.T) {
+	// arrange
+	events := make(chan docker.Event)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return events, make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
+		deleteSubscription: func(ctx context.Context, subscription pubsub.Subscription) error {
+			return errors.New("delete subscription failed")
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	go func() {
+		if err := app.Run(ctx); err != nil {
+			t.Errorf("Expected error to be nil, got %v", err)
+		}
+	}()
+
+	// act
+	events <- docker.Event{
+		Type: docker.EVENT_TYPE_STOP,
+		Container: docker.NewContainer("1", map[string]string{
+			"lacuna.subscription.test.topic":    "test",
+			"lacuna.subscription.test.endpoint": "/messages",
+		}),
+	}
+
+	// assert
+	<-ctx.Done()
+}
+
+func TestRunDeletesOrphanedSubscriptions(t *testing.T) {
+	// arrange
+	containerIDs := make(chan []string, 1)
+	d := &mockDocker{
+		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
+			return make(chan docker.Event), make(chan error, 1)
+		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return []docker.Container{docker.NewContainer("1", map[string]string{})}, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, ids []string) error {
+			containerIDs <- ids
+			return nil
+		},
+	}
+
+	app, err := NewApp(d, p)
+
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// act
+	go app.Run(ctx)
+
+	// assert
+	ids := <-containerIDs
+
+	if len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("Expected running container ids to be [1], got %v", ids)
+	}
 }
@@ -1,21 +1,53 @@
 package app
 
 import (
+	"fmt"
 	"io/fs"
 
+	"github.com/aplr/lacuna/proxy"
 	"github.com/aplr/lacuna/pubsub"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
+type TopicPolicy string
+
+const (
+	// Delete topics once no running container references them anymore
+	TOPIC_POLICY_DELETE TopicPolicy = "delete"
+	// Never delete topics created by lacuna
+	TOPIC_POLICY_RETAIN TopicPolicy = "retain"
+)
+
+type EndpointMode string
+
+const (
+	// Resolve endpoint hosts from the networks of containers,
+	// for the emulator running in the same docker network
+	ENDPOINT_MODE_NETWORK EndpointMode = "network"
+	// Resolve endpoint hosts from the ports containers publish,
+	// for the emulator running on the host
+	ENDPOINT_MODE_HOST EndpointMode = "host"
+)
+
 type Config struct {
-	LabelPrefix string         `mapstructure:"label_prefix"`
-	PubSub      *pubsub.Config `mapstructure:"pubsub"`
+	LabelPrefix  string         `mapstructure:"label_prefix"`
+	TopicPolicy  TopicPolicy    `mapstructure:"topic_policy"`
+	EndpointMode EndpointMode   `mapstructure:"endpoint_mode"`
+	EndpointHost string         `mapstructure:"endpoint_host"`
+	PubSub       *pubsub.Config `mapstructure:"pubsub"`
+	Proxy        *proxy.Config  `mapstructure:"proxy"`
 }
 
 func init() {
 	viper.BindEnv("label_prefix")
 	viper.SetDefault("label_prefix", "lacuna")
+	viper.BindEnv("topic_policy")
+	viper.SetDefault("topic_policy", TOPIC_POLICY_DELETE)
+	viper.BindEnv("endpoint_mode")
+	viper.SetDefault("endpoint_mode", ENDPOINT_MODE_NETWORK)
+	viper.BindEnv("endpoint_host")
+	viper.SetDefault("endpoint_host", "localhost")
 }
 
 func GetConfig() (*Config, error) {
@@ -41,5 +73,36 @@ func GetConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+func (config *Config) validate() error {
+	switch config.TopicPolicy {
+	case TOPIC_POLICY_DELETE, TOPIC_POLICY_RETAIN:
+	default:
+		return fmt.Errorf("invalid topic policy: %s, must be one of 'delete' or 'retain'", config.TopicPolicy)
+	}
+
+	switch config.EndpointMode {
+	case ENDPOINT_MODE_NETWORK, ENDPOINT_MODE_HOST:
+	default:
+		return fmt.Errorf("invalid endpoint mode: %s, must be one of 'network' or 'host'", config.EndpointMode)
+	}
+
+	if config.PubSub != nil {
+		// the backoff is doubled between probes, so it must not be zero
+		if config.PubSub.ReadyBackoff <= 0 {
+			return fmt.Errorf("invalid pubsub ready backoff: %s, must be positive", config.PubSub.ReadyBackoff)
+		}
+
+		if config.PubSub.ReadyMaxBackoff < config.PubSub.ReadyBackoff {
+			return fmt.Errorf("invalid pubsub ready max backoff: %s, must not be less than the ready backoff", config.PubSub.ReadyMaxBackoff)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+)
+
+func TestValidateAcceptsTopicPolicies(t *testing.T) {
+	// arrange
+	policies := []TopicPolicy{TOPIC_POLICY_DELETE, TOPIC_POLICY_RETAIN}
+
+	for _, policy := range policies {
+		config := Config{TopicPolicy: policy, EndpointMode: ENDPOINT_MODE_NETWORK}
+
+		// act
+		err := config.validate()
+
+		// assert
+		if err != nil {
+			t.Errorf("expected topic policy '%s' to be valid, got %v", policy, err)
+		}
+	}
+}
+
+func TestValidateRejectsZeroReadyBackoff(t *testing.T) {
+	// arrange
+	config := Config{
+		TopicPolicy:  TOPIC_POLICY_DELETE,
+		EndpointMode: ENDPOINT_MODE_NETWORK,
+		PubSub:       &pubsub.Config{ReadyBackoff: 0, ReadyMaxBackoff: time.Second},
+	}
+
+	// act
+	err := config.validate()
+
+	// assert
+	if err == nil {
+		t.Errorf("expected zero ready backoff to be rejected")
+	}
+}
+
+func TestValidateRejectsInvalidTopicPolicy(t *testing.T) {
+	// arrange
+	config := Config{TopicPolicy: "retian"}
+
+	// act
+	err := config.validate()
+
+	// assert
+	if err == nil {
+		t.Errorf("expected invalid topic policy to be rejected")
+	}
+}
+
+func TestValidateRejectsInvalidEndpointMode(t *testing.T) {
+	// arrange
+	config := Config{TopicPolicy: TOPIC_POLICY_DELETE, EndpointMode: "hots"}
+
+	// act
+	err := config.validate()
+
+	// assert
+	if err == nil {
+		t.Errorf("expected invalid endpoint mode to be rejected")
+	}
+}
@@ -15,8 +15,18 @@ func TestRunExitsWhenContextCancelled(t *testing.T) {
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return make(chan docker.Event), make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 	}
-	p := &mockPubSub{}
 
 	app, err := NewApp(d, p)
 
@@ -42,8 +52,18 @@ func TestRunExitsOnSigint(t *testing.T) {
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return make(chan docker.Event), make(chan error, 1)
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 	}
-	p := &mockPubSub{}
 
 	app, err := NewApp(d, p)
 
@@ -74,8 +94,18 @@ func TestRunExitsOnAppError(t *testing.T) {
 		run: func(ctx context.Context) (<-chan docker.Event, <-chan error) {
 			return make(chan docker.Event), errs
 		},
+		containers: func(ctx context.Context) ([]docker.Container, error) {
+			return nil, nil
+		},
+	}
+	p := &mockPubSub{
+		ping: func(ctx context.Context) error {
+			return nil
+		},
+		deleteOrphanedSubscriptions: func(ctx context.Context, containerIDs []string) error {
+			return nil
+		},
 	}
-	p := &mockPubSub{}
 
 	app, err := NewApp(d, p)
 
@@ -11,7 +11,9 @@ var _ = docker.Docker(&mockDocker{})
 type mockDocker struct {
 	docker.Docker
 
-	run func(ctx context.Context) (<-chan docker.Event, <-chan error)
+	run        func(ctx context.Context) (<-chan docker.Event, <-chan error)
+	containers func(ctx context.Context) ([]docker.Container, error)
+	inspect    func(ctx context.Context, containerID string) (docker.Container, error)
 }
 
 func (d *mockDocker) Run(ctx context.Context) (<-chan docker.Event, <-chan error) {
@@ -21,3 +23,19 @@ func (d *mockDocker) Run(ctx context.Context) (<-chan docker.Event, <-chan error
 
 	return d.run(ctx)
 }
+
+func (d *mockDocker) Containers(ctx context.Context) ([]docker.Container, error) {
+	if d.containers == nil {
+		panic("no mock function provided")
+	}
+
+	return d.containers(ctx)
+}
+
+func (d *mockDocker) Inspect(ctx context.Context, containerID string) (docker.Container, error) {
+	if d.inspect == nil {
+		panic("no mock function provided")
+	}
+
+	return d.inspect(ctx, containerID)
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"context"
+
+	"github.com/aplr/lacuna/proxy"
+)
+
+var _ = proxy.Proxy(&mockProxy{})
+
+type mockProxy struct {
+	proxy.Proxy
+
+	run        func(ctx context.Context) error
+	register   func(route proxy.Route) string
+	unregister func(route proxy.Route)
+}
+
+func (p *mockProxy) Run(ctx context.Context) error {
+	if p.run == nil {
+		panic("no mock function provided")
+	}
+
+	return p.run(ctx)
+}
+
+func (p *mockProxy) Register(route proxy.Route) string {
+	if p.register == nil {
+		panic("no mock function provided")
+	}
+
+	return p.register(route)
+}
+
+func (p *mockProxy) Unregister(route proxy.Route) {
+	if p.unregister == nil {
+		panic("no mock function provided")
+	}
+
+	p.unregister(route)
+}
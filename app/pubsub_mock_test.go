@@ -11,8 +11,38 @@ var _ = pubsub.PubSub(&mockPubSub{})
 type mockPubSub struct {
 	pubsub.PubSub
 
-	createSubscription func(ctx context.Context, subscription pubsub.Subscription) error
-	deleteSubscription func(ctx context.Context, subscription pubsub.Subscription) error
+	ping                func(ctx context.Context) error
+	createTopic         func(ctx context.Context, topic pubsub.Topic) error
+	deleteTopic         func(ctx context.Context, topic pubsub.Topic) error
+	createSubscription  func(ctx context.Context, subscription pubsub.Subscription) error
+	deleteSubscription  func(ctx context.Context, subscription pubsub.Subscription) error
+	suspendSubscription func(ctx context.Context, subscription pubsub.Subscription) error
+
+	deleteOrphanedSubscriptions func(ctx context.Context, containerIDs []string) error
+}
+
+func (ps *mockPubSub) Ping(ctx context.Context) error {
+	if ps.ping == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.ping(ctx)
+}
+
+func (ps *mockPubSub) CreateTopic(ctx context.Context, topic pubsub.Topic) error {
+	if ps.createTopic == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.createTopic(ctx, topic)
+}
+
+func (ps *mockPubSub) DeleteTopic(ctx context.Context, topic pubsub.Topic) error {
+	if ps.deleteTopic == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.deleteTopic(ctx, topic)
 }
 
 func (ps *mockPubSub) CreateSubscription(ctx context.Context, subscription pubsub.Subscription) error {
@@ -30,3 +60,19 @@ func (ps *mockPubSub) DeleteSubscription(ctx context.Context, subscription pubsu
 
 	return ps.deleteSubscription(ctx, subscription)
 }
+
+func (ps *mockPubSub) SuspendSubscription(ctx context.Context, subscription pubsub.Subscription) error {
+	if ps.suspendSubscription == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.suspendSubscription(ctx, subscription)
+}
+
+func (ps *mockPubSub) DeleteOrphanedSubscriptions(ctx context.Context, containerIDs []string) error {
+	if ps.deleteOrphanedSubscriptions == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.deleteOrphanedSubscriptions(ctx, containerIDs)
+}
@@ -0,0 +1,65 @@
+package app
+
+import "sync"
+
+// topicReferences keeps track of the running containers referencing a topic,
+// either as a subscription topic, dead-letter topic or declared topic.
+type topicReferences struct {
+	mu       sync.Mutex
+	refs     map[string]map[string]struct{}
+	retained map[string]bool
+}
+
+func newTopicReferences() *topicReferences {
+	return &topicReferences{
+		refs:     make(map[string]map[string]struct{}),
+		retained: make(map[string]bool),
+	}
+}
+
+// acquire adds a reference from the container to the topic. Retained topics
+// are kept even after their last reference was released.
+func (r *topicReferences) acquire(topic string, containerID string, retain bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.refs[topic]; !ok {
+		r.refs[topic] = make(map[string]struct{})
+	}
+
+	r.refs[topic][containerID] = struct{}{}
+
+	if retain {
+		r.retained[topic] = true
+	}
+}
+
+// release removes the container's reference to the topic, and returns
+// true if the topic is no longer referenced and should be deleted.
+func (r *topicReferences) release(topic string, containerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	containers, ok := r.refs[topic]
+
+	if !ok {
+		return false
+	}
+
+	if _, ok := containers[containerID]; !ok {
+		return false
+	}
+
+	delete(containers, containerID)
+
+	if len(containers) > 0 {
+		return false
+	}
+
+	retained := r.retained[topic]
+
+	delete(r.refs, topic)
+	delete(r.retained, topic)
+
+	return !retained
+}
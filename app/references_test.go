@@ -0,0 +1,54 @@
+package app
+
+import "testing"
+
+func TestReleaseReturnsTrueForLastReference(t *testing.T) {
+	// arrange
+	refs := newTopicReferences()
+	refs.acquire("test", "1", false)
+	refs.acquire("test", "2", false)
+
+	// act
+	first := refs.release("test", "1")
+	last := refs.release("test", "2")
+
+	// assert
+	if first {
+		t.Errorf("expected topic to still be referenced")
+	}
+
+	if !last {
+		t.Errorf("expected topic to be unreferenced")
+	}
+}
+
+func TestReleaseReturnsFalseForRetainedTopic(t *testing.T) {
+	// arrange
+	refs := newTopicReferences()
+	refs.acquire("test", "1", true)
+	refs.acquire("test", "2", false)
+
+	// act
+	refs.release("test", "1")
+	last := refs.release("test", "2")
+
+	// assert
+	if last {
+		t.Errorf("expected retained topic not to be deleted")
+	}
+}
+
+func TestReleaseReturnsFalseForUnknownReference(t *testing.T) {
+	// arrange
+	refs := newTopicReferences()
+	refs.acquire("test", "1", false)
+
+	// act
+	unknownTopic := refs.release("other", "1")
+	unknownContainer := refs.release("test", "2")
+
+	// assert
+	if unknownTopic || unknownContainer {
+		t.Errorf("expected unknown references not to be released")
+	}
+}
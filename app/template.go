@@ -0,0 +1,198 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/aplr/lacuna/docker"
+	log "github.com/sirupsen/logrus"
+)
+
+// templateData is the data label values are expanded with.
+type templateData struct {
+	Container struct {
+		ID   string
+		Name string
+		// Environment variables of the container
+		Env map[string]string
+	}
+	Compose struct {
+		Project string
+		Service string
+	}
+	// Environment variables of lacuna
+	Env map[string]string
+	// Host of the container by network, compose networks
+	// are available with and without their project prefix
+	Networks map[string]string
+	// Subscription the label belongs to, if any
+	Subscription struct {
+		Name string
+	}
+	// Topic the label belongs to, if any
+	Topic struct {
+		Name string
+	}
+}
+
+// expandedLabels keeps the expanded labels of running containers, so they
+// are released using the values they were started with, as the networks
+// of containers are gone once they stop.
+type expandedLabels struct {
+	mu     sync.Mutex
+	labels map[string]map[string]string
+}
+
+func newExpandedLabels() *expandedLabels {
+	return &expandedLabels{
+		labels: make(map[string]map[string]string),
+	}
+}
+
+// expand returns the container with its labels expanded, and
+// keeps them until the container is released.
+func (e *expandedLabels) expand(container docker.Container) docker.Container {
+	if !hasTemplates(container) {
+		return container
+	}
+
+	container = expandLabels(container)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.labels[container.ID] = container.Labels
+
+	return container
+}
+
+// release returns the container with the labels it was expanded with
+// while running, or expands them again if it was not expanded before.
+func (e *expandedLabels) release(container docker.Container) docker.Container {
+	e.mu.Lock()
+	labels, ok := e.labels[container.ID]
+	delete(e.labels, container.ID)
+	e.mu.Unlock()
+
+	if !ok {
+		return expandLabels(container)
+	}
+
+	container.Labels = labels
+
+	return container
+}
+
+// hasTemplates reports whether any lacuna label of the container is a template.
+func hasTemplates(container docker.Container) bool {
+	for key, value := range container.Labels {
+		if strings.HasPrefix(key, labelPrefix+".") && strings.Contains(value, "{{") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandLabels returns the container with its lacuna labels expanded as
+// text/template templates. Labels whose template is invalid or fails to
+// execute are removed, and reported individually.
+func expandLabels(container docker.Container) docker.Container {
+	if !hasTemplates(container) {
+		return container
+	}
+
+	data := newTemplateData(container)
+	labels := make(map[string]string, len(container.Labels))
+
+	for key, value := range container.Labels {
+		if !strings.HasPrefix(key, labelPrefix+".") || !strings.Contains(value, "{{") {
+			labels[key] = value
+			continue
+		}
+
+		keyParts := strings.Split(key, ".")
+
+		data.Subscription.Name = ""
+		data.Topic.Name = ""
+
+		if len(keyParts) > 2 {
+			switch keyParts[1] {
+			case "subscription":
+				data.Subscription.Name = strings.ToLower(keyParts[2])
+			case "topic":
+				data.Topic.Name = keyParts[2]
+			}
+		}
+
+		expanded, err := expandTemplate(key, value, data)
+
+		if err != nil {
+			log.Warnf("skipping label: %s, invalid template: %v\n", key, err)
+			continue
+		}
+
+		labels[key] = expanded
+	}
+
+	container.Labels = labels
+
+	return container
+}
+
+func expandTemplate(key string, value string, data templateData) (string, error) {
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func newTemplateData(container docker.Container) templateData {
+	var data templateData
+
+	data.Container.ID = container.ID
+	data.Container.Name = container.Name()
+	data.Container.Env = container.Env
+	data.Compose.Project = container.ComposeProject()
+	data.Compose.Service = container.Labels["com.docker.compose.service"]
+
+	if data.Container.Env == nil {
+		data.Container.Env = make(map[string]string)
+	}
+
+	data.Env = make(map[string]string)
+
+	for _, variable := range os.Environ() {
+		key, value, _ := strings.Cut(variable, "=")
+		data.Env[key] = value
+	}
+
+	data.Networks = make(map[string]string, len(container.Networks))
+
+	for name := range container.Networks {
+		host, err := container.NetworkHost(name)
+
+		if err != nil {
+			continue
+		}
+
+		data.Networks[name] = host
+
+		if project := data.Compose.Project; project != "" && strings.HasPrefix(name, project+"_") {
+			data.Networks[strings.TrimPrefix(name, project+"_")] = host
+		}
+	}
+
+	return data
+}
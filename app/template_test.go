@@ -0,0 +1,101 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/aplr/lacuna/docker"
+)
+
+func templatedContainer(labels map[string]string) docker.Container {
+	container := docker.NewContainer("1", map[string]string{
+		"com.docker.compose.project": "project",
+		"com.docker.compose.service": "api",
+	})
+
+	for key, value := range labels {
+		container.Labels[key] = value
+	}
+
+	container.Env = map[string]string{"PORT": "8080"}
+	container.Networks = map[string]docker.Network{
+		"project_backend": {Name: "project_backend", IP: "172.18.0.2"},
+	}
+
+	return container
+}
+
+func TestExpandLabelsExpandsTemplates(t *testing.T) {
+	// arrange
+	t.Setenv("LACUNA_TEST_TOPIC", "orders")
+
+	container := templatedContainer(map[string]string{
+		"lacuna.subscription.Orders.topic":    "{{.Env.LACUNA_TEST_TOPIC}}",
+		"lacuna.subscription.Orders.endpoint": "http://{{.Compose.Service}}:{{.Container.Env.PORT}}/push/{{.Subscription.Name}}",
+		"lacuna.topic.events.name":            "{{.Compose.Project}}-{{.Topic.Name}}",
+		"lacuna.subscription.direct.endpoint": "http://{{.Networks.backend}}/messages",
+	})
+
+	// act
+	container = expandLabels(container)
+
+	// assert
+	expected := map[string]string{
+		"lacuna.subscription.Orders.topic":    "orders",
+		"lacuna.subscription.Orders.endpoint": "http://api:8080/push/orders",
+		"lacuna.topic.events.name":            "project-events",
+		"lacuna.subscription.direct.endpoint": "http://172.18.0.2/messages",
+		"com.docker.compose.service":          "api",
+	}
+
+	for key, value := range expected {
+		if container.Labels[key] != value {
+			t.Errorf("expected label %s to be '%s', got '%s'", key, value, container.Labels[key])
+		}
+	}
+}
+
+func TestExpandLabelsSkipsInvalidTemplates(t *testing.T) {
+	// arrange
+	container := templatedContainer(map[string]string{
+		"lacuna.subscription.test.topic":    "{{.Topic",
+		"lacuna.subscription.test.endpoint": "http://{{.Container.Env.MISSING}}/messages",
+		"lacuna.subscription.test.filter":   "attributes:{{.Subscription.Name}}",
+	})
+
+	// act
+	container = expandLabels(container)
+
+	// assert
+	if _, ok := container.Labels["lacuna.subscription.test.topic"]; ok {
+		t.Errorf("expected label with invalid template to be skipped")
+	}
+
+	if _, ok := container.Labels["lacuna.subscription.test.endpoint"]; ok {
+		t.Errorf("expected label with missing key to be skipped")
+	}
+
+	if container.Labels["lacuna.subscription.test.filter"] != "attributes:test" {
+		t.Errorf("expected valid label to be expanded, got '%s'", container.Labels["lacuna.subscription.test.filter"])
+	}
+}
+
+func TestExpandedLabelsReleasesLabelsOfStartedContainer(t *testing.T) {
+	// arrange
+	labels := newExpandedLabels()
+	container := templatedContainer(map[string]string{
+		"lacuna.subscription.test.endpoint": "http://{{.Networks.backend}}/messages",
+	})
+
+	labels.expand(container)
+
+	// the networks of stopped containers are gone
+	container.Networks = nil
+
+	// act
+	container = labels.release(container)
+
+	// assert
+	if container.Labels["lacuna.subscription.test.endpoint"] != "http://172.18.0.2/messages" {
+		t.Errorf("expected labels the container was started with, got '%s'", container.Labels["lacuna.subscription.test.endpoint"])
+	}
+}
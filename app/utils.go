@@ -7,10 +7,27 @@ import (
 	"time"
 
 	"github.com/aplr/lacuna/docker"
+	"github.com/aplr/lacuna/filter"
+	"github.com/aplr/lacuna/proxy"
 	"github.com/aplr/lacuna/pubsub"
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	// Subscription options whose labels carry an additional key segment,
+	// i.e. 'lacuna.subscription.<name>.<option>.<key>'
+	nestedSubscriptionOptions = map[string]bool{
+		"push-attribute": true,
+		"chaos":          true,
+	}
+
+	// Topic options whose labels carry an additional key segment,
+	// i.e. 'lacuna.topic.<name>.<option>.<key>'
+	nestedTopicOptions = map[string]bool{
+		"label": true,
+	}
+)
+
 func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 	subscriptions := make([]pubsub.Subscription, 0)
 	nameRegex := regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
@@ -28,11 +45,17 @@ func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 		}
 
 		// Check that the key has the correct number of parts
-		if len(keyParts) != 4 {
+		if len(keyParts) != 4 && !(len(keyParts) == 5 && nestedSubscriptionOptions[keyParts[3]]) {
 			log.Warnf("invalid subscription key: %s, must be in the format 'lacuna.subscription.<name>.<option>'\n", key)
 			continue
 		}
 
+		// Check that nested options carry their key
+		if len(keyParts) == 4 && nestedSubscriptionOptions[keyParts[3]] {
+			log.Warnf("invalid subscription key: %s, must be in the format 'lacuna.subscription.<name>.%s.<key>'\n", key, keyParts[3])
+			continue
+		}
+
 		// Check that the subscription name is valid
 		if !nameRegex.MatchString(keyParts[2]) {
 			log.Warnf("invalid subscription name in key: %s, subscription name should be alphanumeric and may contain dashes\n", key)
@@ -46,15 +69,36 @@ func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 			subscriptionMap[name] = &pubsub.Subscription{
 				Service: container.Name(),
 				Name:    name,
+				Type:    pubsub.SUBSCRIPTION_TYPE_PUSH,
+				Labels:  pubsub.OwnershipLabels(container.ID, container.ComposeProject()),
 			}
 		}
 
 		// Assign the value to the correct field
 		switch keyParts[3] {
+		case "project":
+			subscriptionMap[name].Project = value
+		case "type":
+			switch subscriptionType := pubsub.SubscriptionType(value); subscriptionType {
+			case pubsub.SUBSCRIPTION_TYPE_PUSH, pubsub.SUBSCRIPTION_TYPE_PULL:
+				subscriptionMap[name].Type = subscriptionType
+			default:
+				log.Warnf("invalid type: %s, must be one of 'push' or 'pull'\n", value)
+				continue
+			}
 		case "topic":
 			subscriptionMap[name].Topic = value
 		case "endpoint":
 			subscriptionMap[name].Endpoint = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				log.Warnf("invalid port value: %s, must be a valid port number\n", value)
+				continue
+			}
+			subscriptionMap[name].EndpointPort = port
+		case "network":
+			subscriptionMap[name].EndpointNetwork = value
 		case "ack-deadline":
 			deadline, err := time.ParseDuration(value)
 			if err != nil {
@@ -101,6 +145,13 @@ func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 			subscriptionMap[name].DeliverExactlyOnce = deliver
 		case "dead-letter-topic":
 			subscriptionMap[name].DeadLetterTopic = value
+		case "dead-letter-subscription":
+			enable, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid dead-letter-subscription value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			subscriptionMap[name].DeadLetterSubscription = enable
 		case "max-dead-letter-delivery-attempts":
 			attempts, err := strconv.Atoi(value)
 			if err != nil {
@@ -122,6 +173,45 @@ func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 				continue
 			}
 			subscriptionMap[name].RetryMaximumBackoff = &backoff
+		case "oidc-service-account-email":
+			subscriptionMap[name].OIDCServiceAccountEmail = value
+		case "oidc-audience":
+			subscriptionMap[name].OIDCAudience = value
+		case "push-no-wrapper":
+			noWrapper, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid push-no-wrapper value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			subscriptionMap[name].PushNoWrapper = noWrapper
+		case "push-write-metadata":
+			writeMetadata, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid push-write-metadata value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			subscriptionMap[name].PushWriteMetadata = writeMetadata
+		case "push-attribute":
+			if subscriptionMap[name].PushAttributes == nil {
+				subscriptionMap[name].PushAttributes = make(map[string]string)
+			}
+			subscriptionMap[name].PushAttributes[keyParts[4]] = value
+		case "proxy":
+			proxy, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid proxy value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			subscriptionMap[name].Proxy = proxy
+		case "chaos":
+			// chaos options are applied by the proxy, see extractChaos
+		case "keep-backlog":
+			keep, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid keep-backlog value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			subscriptionMap[name].KeepBacklog = keep
 		default:
 			log.Warnf("skipping invalid subscription key: %s, must be one of 'topic' or 'endpoint'\n", key)
 		}
@@ -129,13 +219,315 @@ func extractSubscriptions(container docker.Container) []pubsub.Subscription {
 
 	// Convert map to slice, only consider valid subscriptions
 	for _, subscription := range subscriptionMap {
-		if subscription.Topic != "" && subscription.Endpoint != "" {
-			// Only include subscriptions with both topic and endpoint populated
-			subscriptions = append(subscriptions, *subscription)
-		} else {
+		if subscription.Topic == "" {
+			log.Warnf("skipping incomplete subscription: %s, a topic must be provided\n", subscription.Name)
+			continue
+		}
+
+		// Push subscriptions require an endpoint, pull subscriptions must not have one
+		if subscription.IsPush() && subscription.Endpoint == "" {
 			log.Warnf("skipping incomplete subscription: %s, both topic and endpoint must be provided\n", subscription.Name)
+			continue
+		}
+
+		if !subscription.IsPush() && subscription.Endpoint != "" {
+			log.Warnf("ignoring endpoint of pull subscription: %s\n", subscription.Name)
+			subscription.Endpoint = ""
+		}
+
+		if !subscription.HasEndpointPath() && (subscription.EndpointPort != 0 || subscription.EndpointNetwork != "") {
+			log.Warnf("ignoring port and network of subscription: %s, endpoint must be a path\n", subscription.Name)
+			subscription.EndpointPort = 0
+			subscription.EndpointNetwork = ""
+		}
+
+		if subscription.OIDCAudience != "" && subscription.OIDCServiceAccountEmail == "" {
+			log.Warnf("ignoring oidc-audience of subscription: %s, oidc-service-account-email must be provided\n", subscription.Name)
+			subscription.OIDCAudience = ""
+		}
+
+		if subscription.DeadLetterSubscription && subscription.DeadLetterTopic == "" {
+			log.Warnf("ignoring dead-letter-subscription of subscription: %s, dead-letter-topic must be provided\n", subscription.Name)
+			subscription.DeadLetterSubscription = false
 		}
+
+		// invalid filters are rejected by pub/sub, but not reliably by the emulator
+		if subscription.Filter != "" {
+			if _, err := filter.Parse(subscription.Filter); err != nil {
+				log.Warnf("skipping subscription: %s, invalid filter: %v\n", subscription.Name, err)
+				continue
+			}
+		}
+
+		if subscription.PushWriteMetadata && !subscription.PushNoWrapper {
+			log.Warnf("ignoring push-write-metadata of subscription: %s, push-no-wrapper must be enabled\n", subscription.Name)
+			subscription.PushWriteMetadata = false
+		}
+
+		subscriptions = append(subscriptions, *subscription)
 	}
 
 	return subscriptions
 }
+
+func extractTopics(container docker.Container) []pubsub.Topic {
+	topics := make([]pubsub.Topic, 0)
+	nameRegex := regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+	// Intermediate storage to hold topics as we process labels
+	topicMap := make(map[string]*pubsub.Topic)
+
+	// Gather topics by processing a container's labels
+	for key, value := range container.Labels {
+		keyParts := strings.Split(key, ".")
+
+		// Check that the key starts with lacuna.topic
+		if len(keyParts) < 2 || keyParts[0] != labelPrefix || keyParts[1] != "topic" {
+			continue
+		}
+
+		// Check that the key has the correct number of parts
+		if len(keyParts) != 4 && !(len(keyParts) == 5 && nestedTopicOptions[keyParts[3]]) {
+			log.Warnf("invalid topic key: %s, must be in the format 'lacuna.topic.<name>.<option>'\n", key)
+			continue
+		}
+
+		// Check that nested options carry their key
+		if len(keyParts) == 4 && nestedTopicOptions[keyParts[3]] {
+			log.Warnf("invalid topic key: %s, must be in the format 'lacuna.topic.<name>.%s.<key>'\n", key, keyParts[3])
+			continue
+		}
+
+		// Check that the topic name is valid
+		if !nameRegex.MatchString(keyParts[2]) {
+			log.Warnf("invalid topic name in key: %s, topic name should be alphanumeric and may contain dashes\n", key)
+			continue
+		}
+
+		name := keyParts[2]
+
+		// Check if topic already exists in the map, the topic
+		// name defaults to the name used in the label key
+		if _, ok := topicMap[name]; !ok {
+			topicMap[name] = &pubsub.Topic{
+				Name:   name,
+				Labels: pubsub.OwnershipLabels(container.ID, container.ComposeProject()),
+			}
+		}
+
+		// Assign the value to the correct field
+		switch keyParts[3] {
+		case "name":
+			topicMap[name].Name = value
+		case "project":
+			topicMap[name].Project = value
+		case "retention-duration":
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				log.Warnf("invalid retention-duration: %s, must be a valid duration\n", value)
+				continue
+			}
+			topicMap[name].RetentionDuration = duration
+		case "retain":
+			retain, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Warnf("invalid retain value: %s, must be a valid boolean\n", value)
+				continue
+			}
+			topicMap[name].Retain = retain
+		case "schema":
+			topicMap[name].Schema = value
+		case "schema-file":
+			topicMap[name].SchemaFile = value
+		case "schema-type":
+			switch schemaType := pubsub.SchemaType(value); schemaType {
+			case pubsub.SCHEMA_TYPE_AVRO, pubsub.SCHEMA_TYPE_PROTOBUF:
+				topicMap[name].SchemaType = schemaType
+			default:
+				log.Warnf("invalid schema-type: %s, must be one of 'avro' or 'protobuf'\n", value)
+				continue
+			}
+		case "schema-encoding":
+			switch encoding := pubsub.SchemaEncoding(value); encoding {
+			case pubsub.SCHEMA_ENCODING_JSON, pubsub.SCHEMA_ENCODING_BINARY:
+				topicMap[name].SchemaEncoding = encoding
+			default:
+				log.Warnf("invalid schema-encoding: %s, must be one of 'json' or 'binary'\n", value)
+				continue
+			}
+		case "label":
+			topicMap[name].Labels[keyParts[4]] = value
+		default:
+			log.Warnf("skipping invalid topic key: %s\n", key)
+		}
+	}
+
+	// Convert map to slice, only consider valid topics
+	for _, topic := range topicMap {
+		if topic.Name == "" {
+			log.Warnf("skipping topic with empty name\n")
+			continue
+		}
+
+		if topic.HasSchema() {
+			// A schema definition requires a type to be parsed
+			if topic.SchemaType == "" {
+				log.Warnf("skipping topic: %s, schema-type must be provided along with schema-file\n", topic.Name)
+				continue
+			}
+
+			if topic.Schema == "" {
+				topic.Schema = topic.Name
+			}
+
+			if topic.SchemaEncoding == "" {
+				topic.SchemaEncoding = pubsub.SCHEMA_ENCODING_JSON
+			}
+		} else if topic.Schema != "" || topic.SchemaType != "" || topic.SchemaEncoding != "" {
+			log.Warnf("ignoring schema of topic: %s, schema-file must be provided\n", topic.Name)
+			topic.Schema, topic.SchemaType, topic.SchemaEncoding = "", "", ""
+		}
+
+		topics = append(topics, *topic)
+	}
+
+	return topics
+}
+
+// extractChaos returns the chaos options of the container's subscriptions, given
+// as 'lacuna.subscription.<name>.chaos.<option>', mapped by subscription name.
+func extractChaos(container docker.Container) map[string]proxy.Chaos {
+	chaos := make(map[string]proxy.Chaos)
+
+	for key, value := range container.Labels {
+		keyParts := strings.Split(key, ".")
+
+		if len(keyParts) != 5 || keyParts[0] != labelPrefix || keyParts[1] != "subscription" || keyParts[3] != "chaos" {
+			continue
+		}
+
+		name := strings.ToLower(keyParts[2])
+		options := chaos[name]
+
+		switch keyParts[4] {
+		case "error-rate", "duplicate-rate", "drop-rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				log.Warnf("invalid chaos.%s value: %s, must be a number between 0 and 1\n", keyParts[4], value)
+				continue
+			}
+			switch keyParts[4] {
+			case "error-rate":
+				options.ErrorRate = rate
+			case "duplicate-rate":
+				options.DuplicateRate = rate
+			case "drop-rate":
+				options.DropRate = rate
+			}
+		case "latency":
+			latency, err := time.ParseDuration(value)
+			if err != nil || latency < 0 {
+				log.Warnf("invalid chaos.latency: %s, must be a valid duration\n", value)
+				continue
+			}
+			options.Latency = latency
+		default:
+			log.Warnf("skipping invalid chaos key: %s\n", key)
+			continue
+		}
+
+		chaos[name] = options
+	}
+
+	return chaos
+}
+
+// extractRetainTopics reports whether the container opted out of deleting
+// the topics it references once they are no longer used.
+func extractRetainTopics(container docker.Container) bool {
+	value, ok := container.Labels[labelPrefix+".retain-topics"]
+
+	if !ok {
+		return false
+	}
+
+	retain, err := strconv.ParseBool(value)
+
+	if err != nil {
+		log.Warnf("invalid retain-topics value: %s, must be a valid boolean\n", value)
+		return false
+	}
+
+	return retain
+}
+
+// waitsForHealthy reports whether endpoints of the container are only attached
+// while it is healthy. This applies to containers with a healthcheck, unless
+// disabled using the wait-for-healthy label.
+func waitsForHealthy(container docker.Container) bool {
+	value, ok := container.Labels[labelPrefix+".wait-for-healthy"]
+
+	if !ok {
+		return container.HasHealthcheck()
+	}
+
+	wait, err := strconv.ParseBool(value)
+
+	if err != nil {
+		log.Warnf("invalid wait-for-healthy value: %s, must be a valid boolean\n", value)
+		return container.HasHealthcheck()
+	}
+
+	if wait && !container.HasHealthcheck() {
+		log.Warnf("ignoring wait-for-healthy of container: %s, it has no healthcheck\n", container.Name())
+		return false
+	}
+
+	return wait
+}
+
+// isAvailable reports whether the container accepts push requests, i.e. it
+// is neither paused nor waiting to become healthy.
+func isAvailable(container docker.Container) bool {
+	if container.Paused {
+		return false
+	}
+
+	return !waitsForHealthy(container) || container.Health == docker.HEALTH_STATUS_HEALTHY
+}
+
+// deliversToContainer reports whether messages of the subscription are
+// pushed to its container, unlike pull subscriptions and capture sinks.
+func deliversToContainer(subscription pubsub.Subscription) bool {
+	return subscription.IsPush() && !subscription.IsCapture()
+}
+
+// referencedTopics returns the fully qualified names of all topics referenced by a
+// container, mapped to whether the topic should be retained once it is unreferenced.
+func referencedTopics(container docker.Container, topics []pubsub.Topic, subscriptions []pubsub.Subscription, projectID string) map[string]bool {
+	retain := extractRetainTopics(container)
+	references := make(map[string]bool)
+
+	for _, topic := range topics {
+		name := topic.QualifiedName(projectID)
+		references[name] = references[name] || retain || topic.Retain
+	}
+
+	for _, subscription := range subscriptions {
+		// subscriptions keeping their backlog must outlive their container,
+		// so the topics they are attached to must not be deleted either
+		retain := retain || subscription.KeepBacklog
+
+		topic := subscription.GetTopic()
+		name := topic.QualifiedName(projectID)
+		references[name] = references[name] || retain
+
+		if subscription.DeadLetterTopic != "" {
+			deadLetterTopic := subscription.GetDeadLetterTopic()
+			name := deadLetterTopic.QualifiedName(projectID)
+			references[name] = references[name] || retain
+		}
+	}
+
+	return references
+}
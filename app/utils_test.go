@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/aplr/lacuna/docker"
+	"github.com/aplr/lacuna/pubsub"
 )
 
 func TestExtractSubscriptionsSucceedsWithoutLabels(t *testing.T) {
@@ -59,12 +60,17 @@ func TestExtractSubscriptionsExtractsValidSubscriptionOptions(t *testing.T) {
 		"lacuna.subscription.test.retention-duration":                "24h",
 		"lacuna.subscription.test.enable-ordering":                   "true",
 		"lacuna.subscription.test.expiration-ttl":                    "5s",
-		"lacuna.subscription.test.filter":                            "foo=bar",
+		"lacuna.subscription.test.filter":                            `attributes.foo = "bar"`,
 		"lacuna.subscription.test.deliver-exactly-once":              "true",
 		"lacuna.subscription.test.dead-letter-topic":                 "dead-letter-topic",
 		"lacuna.subscription.test.max-dead-letter-delivery-attempts": "10",
 		"lacuna.subscription.test.retry-minimum-backoff":             "10s",
 		"lacuna.subscription.test.retry-maximum-backoff":             "10s",
+		"lacuna.subscription.test.oidc-service-account-email":        "push@project.iam.gserviceaccount.com",
+		"lacuna.subscription.test.oidc-audience":                     "https://test",
+		"lacuna.subscription.test.push-no-wrapper":                   "true",
+		"lacuna.subscription.test.push-write-metadata":               "true",
+		"lacuna.subscription.test.push-attribute.x-goog-version":     "v1",
 	})
 
 	// act
@@ -107,8 +113,8 @@ func TestExtractSubscriptionsExtractsValidSubscriptionOptions(t *testing.T) {
 		t.Errorf("expected expiration-ttl to be 5s, got '%d'", subscriptions[0].ExpirationTTL)
 	}
 
-	if subscriptions[0].Filter != "foo=bar" {
-		t.Errorf("expected filter to be 'foo=bar', got '%s'", subscriptions[0].Filter)
+	if subscriptions[0].Filter != `attributes.foo = "bar"` {
+		t.Errorf("expected filter to be 'attributes.foo = \"bar\"', got '%s'", subscriptions[0].Filter)
 	}
 
 	if subscriptions[0].DeliverExactlyOnce != true {
@@ -130,6 +136,26 @@ func TestExtractSubscriptionsExtractsValidSubscriptionOptions(t *testing.T) {
 	if *subscriptions[0].RetryMaximumBackoff != 10*time.Second {
 		t.Errorf("expected retry-maximum-backoff to be 10s, got '%d'", subscriptions[0].RetryMaximumBackoff)
 	}
+
+	if subscriptions[0].OIDCServiceAccountEmail != "push@project.iam.gserviceaccount.com" {
+		t.Errorf("expected oidc-service-account-email to be 'push@project.iam.gserviceaccount.com', got '%s'", subscriptions[0].OIDCServiceAccountEmail)
+	}
+
+	if subscriptions[0].OIDCAudience != "https://test" {
+		t.Errorf("expected oidc-audience to be 'https://test', got '%s'", subscriptions[0].OIDCAudience)
+	}
+
+	if subscriptions[0].PushNoWrapper != true {
+		t.Errorf("expected push-no-wrapper to be true, got '%t'", subscriptions[0].PushNoWrapper)
+	}
+
+	if subscriptions[0].PushWriteMetadata != true {
+		t.Errorf("expected push-write-metadata to be true, got '%t'", subscriptions[0].PushWriteMetadata)
+	}
+
+	if subscriptions[0].PushAttributes["x-goog-version"] != "v1" {
+		t.Errorf("expected push-attribute.x-goog-version to be 'v1', got '%s'", subscriptions[0].PushAttributes["x-goog-version"])
+	}
 }
 
 func TestExtractSubscriptionsExtractsMultipleSubscriptions(t *testing.T) {
@@ -214,6 +240,27 @@ func TestExtractSubscriptionsSkipsInvalidLabels(t *testing.T) {
 	}
 }
 
+func TestExtractSubscriptionsSkipsNestedOptionWithoutKey(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":          "test-topic",
+		"lacuna.subscription.test.endpoint":       "/messages",
+		"lacuna.subscription.test.push-attribute": "value",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if len(subscriptions[0].PushAttributes) != 0 {
+		t.Errorf("expected push attribute without key to be skipped, got %v", subscriptions[0].PushAttributes)
+	}
+}
+
 func TestExtractSubscriptionsSkipsInvalidValues(t *testing.T) {
 	// arrange
 	container := docker.NewContainer("1", map[string]string{
@@ -228,6 +275,8 @@ func TestExtractSubscriptionsSkipsInvalidValues(t *testing.T) {
 		"lacuna.subscription.test.max-dead-letter-delivery-attempts": "invalid",
 		"lacuna.subscription.test.retry-minimum-backoff":             "invalid",
 		"lacuna.subscription.test.retry-maximum-backoff":             "invalid",
+		"lacuna.subscription.test.push-no-wrapper":                   "invalid",
+		"lacuna.subscription.test.push-write-metadata":               "invalid",
 	})
 
 	// act
@@ -240,3 +289,461 @@ func TestExtractSubscriptionsSkipsInvalidValues(t *testing.T) {
 
 	// TODO: check if subscription has default values for invalid fields
 }
+
+func TestExtractSubscriptionsExtractsPullSubscriptionWithoutEndpoint(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic": "test-topic",
+		"lacuna.subscription.test.type":  "pull",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if subscriptions[0].Type != pubsub.SUBSCRIPTION_TYPE_PULL {
+		t.Errorf("expected type to be 'pull', got '%s'", subscriptions[0].Type)
+	}
+}
+
+func TestExtractSubscriptionsDefaultsToPushSubscription(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":    "test-topic",
+		"lacuna.subscription.test.endpoint": "/messages",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if subscriptions[0].Type != pubsub.SUBSCRIPTION_TYPE_PUSH {
+		t.Errorf("expected type to be 'push', got '%s'", subscriptions[0].Type)
+	}
+}
+
+func TestExtractSubscriptionsSkipsInvalidType(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic": "test-topic",
+		"lacuna.subscription.test.type":  "stream",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 0 {
+		t.Errorf("expected 0 subscriptions, got %d", len(subscriptions))
+	}
+}
+
+func TestExtractTopicsSucceedsWithoutLabels(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 0 {
+		t.Errorf("expected 0 topics, got %d", len(topics))
+	}
+}
+
+func TestExtractTopicsExtractsValidTopicOptions(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.name":               "orders.v1",
+		"lacuna.topic.orders.retention-duration": "1h",
+		"lacuna.topic.orders.label.team":         "payments",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 1 {
+		t.Fatalf("expected 1 topic, got %d", len(topics))
+	}
+
+	if topics[0].Name != "orders.v1" {
+		t.Errorf("expected name to be 'orders.v1', got '%s'", topics[0].Name)
+	}
+
+	if topics[0].RetentionDuration != time.Hour {
+		t.Errorf("expected retention-duration to be 1h, got '%d'", topics[0].RetentionDuration)
+	}
+
+	if topics[0].Labels["team"] != "payments" {
+		t.Errorf("expected label team to be 'payments', got '%s'", topics[0].Labels["team"])
+	}
+}
+
+func TestExtractTopicsDefaultsNameToKey(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.retain": "false",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 1 {
+		t.Fatalf("expected 1 topic, got %d", len(topics))
+	}
+
+	if topics[0].Name != "orders" {
+		t.Errorf("expected name to be 'orders', got '%s'", topics[0].Name)
+	}
+}
+
+func TestExtractTopicsSkipsInvalidLabels(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.my_name.name": "invalid-name",
+		"lacuna.topic.x.y.z":        "invalid-key",
+		"lacuna.topic.x":            "invalid-key",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 0 {
+		t.Errorf("expected 0 topics, got %d", len(topics))
+	}
+}
+
+func TestExtractTopicsSkipsLabelWithoutKey(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.test.name":  "test-topic",
+		"lacuna.topic.test.label": "value",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 1 {
+		t.Fatalf("expected 1 topic, got %d", len(topics))
+	}
+
+	if _, ok := topics[0].Labels[""]; ok {
+		t.Errorf("expected label without key to be skipped, got %v", topics[0].Labels)
+	}
+}
+
+func TestExtractTopicsExtractsSchemaOptions(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.schema-file":     "/schemas/order.avsc",
+		"lacuna.topic.orders.schema-type":     "avro",
+		"lacuna.topic.orders.schema-encoding": "binary",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 1 {
+		t.Fatalf("expected 1 topic, got %d", len(topics))
+	}
+
+	if topics[0].Schema != "orders" {
+		t.Errorf("expected schema to default to 'orders', got '%s'", topics[0].Schema)
+	}
+
+	if topics[0].SchemaFile != "/schemas/order.avsc" {
+		t.Errorf("expected schema-file to be '/schemas/order.avsc', got '%s'", topics[0].SchemaFile)
+	}
+
+	if topics[0].SchemaType != pubsub.SCHEMA_TYPE_AVRO {
+		t.Errorf("expected schema-type to be 'avro', got '%s'", topics[0].SchemaType)
+	}
+
+	if topics[0].SchemaEncoding != pubsub.SCHEMA_ENCODING_BINARY {
+		t.Errorf("expected schema-encoding to be 'binary', got '%s'", topics[0].SchemaEncoding)
+	}
+}
+
+func TestExtractTopicsSkipsSchemaWithoutType(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.schema-file": "/schemas/order.avsc",
+	})
+
+	// act
+	topics := extractTopics(container)
+
+	// assert
+	if len(topics) != 0 {
+		t.Errorf("expected 0 topics, got %d", len(topics))
+	}
+}
+
+func TestReferencedTopicsIncludesAllTopics(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.retain":                                 "true",
+		"lacuna.subscription.test.topic":                             "test-topic",
+		"lacuna.subscription.test.endpoint":                          "/messages",
+		"lacuna.subscription.test.dead-letter-topic":                 "dead-letter-topic",
+		"lacuna.subscription.test.max-dead-letter-delivery-attempts": "5",
+	})
+
+	// act
+	references := referencedTopics(container, extractTopics(container), extractSubscriptions(container), "test")
+
+	// assert
+	if len(references) != 3 {
+		t.Fatalf("expected 3 referenced topics, got %d", len(references))
+	}
+
+	if !references["projects/test/topics/orders"] {
+		t.Errorf("expected topic 'orders' to be retained")
+	}
+
+	if references["projects/test/topics/test-topic"] || references["projects/test/topics/dead-letter-topic"] {
+		t.Errorf("expected subscription topics not to be retained")
+	}
+}
+
+func TestReferencedTopicsRetainsTopicsOfOptedOutContainer(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.retain-topics":              "true",
+		"lacuna.subscription.test.topic":    "test-topic",
+		"lacuna.subscription.test.endpoint": "/messages",
+	})
+
+	// act
+	references := referencedTopics(container, extractTopics(container), extractSubscriptions(container), "test")
+
+	// assert
+	if !references["projects/test/topics/test-topic"] {
+		t.Errorf("expected topic 'test-topic' to be retained")
+	}
+}
+
+func TestReferencedTopicsRetainsTopicsOfSubscriptionsKeepingBacklog(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":        "test-topic",
+		"lacuna.subscription.test.endpoint":     "/messages",
+		"lacuna.subscription.test.keep-backlog": "true",
+	})
+
+	// act
+	references := referencedTopics(container, extractTopics(container), extractSubscriptions(container), "test")
+
+	// assert
+	if !references["projects/test/topics/test-topic"] {
+		t.Errorf("expected topic 'test-topic' to be retained")
+	}
+}
+
+func TestExtractSubscriptionsSkipsSubscriptionWithInvalidFilter(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":    "test",
+		"lacuna.subscription.test.endpoint": "/messages",
+		"lacuna.subscription.test.filter":   "foo=bar",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 0 {
+		t.Errorf("expected subscription with invalid filter to be skipped, got %d subscriptions", len(subscriptions))
+	}
+}
+
+func TestExtractChaosExtractsChaosOptions(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.Test.topic":                "test",
+		"lacuna.subscription.Test.endpoint":             "/messages",
+		"lacuna.subscription.Test.chaos.error-rate":     "0.1",
+		"lacuna.subscription.Test.chaos.latency":        "1s",
+		"lacuna.subscription.Test.chaos.duplicate-rate": "0.2",
+		"lacuna.subscription.Test.chaos.drop-rate":      "2",
+	})
+
+	// act
+	chaos := extractChaos(container)
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	options := chaos[subscriptions[0].Name]
+
+	if options.ErrorRate != 0.1 || options.Latency != time.Second || options.DuplicateRate != 0.2 {
+		t.Errorf("expected chaos options to be extracted, got %+v", options)
+	}
+
+	if options.DropRate != 0 {
+		t.Errorf("expected invalid drop-rate to be skipped, got %v", options.DropRate)
+	}
+}
+
+func TestExtractSubscriptionsExtractsDeadLetterSubscription(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":                    "test-topic",
+		"lacuna.subscription.test.endpoint":                 "/messages",
+		"lacuna.subscription.test.dead-letter-topic":        "dead-letter-topic",
+		"lacuna.subscription.test.dead-letter-subscription": "true",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if !subscriptions[0].DeadLetterSubscription {
+		t.Errorf("expected dead-letter-subscription to be true")
+	}
+}
+
+func TestExtractSubscriptionsIgnoresDeadLetterSubscriptionWithoutTopic(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":                    "test-topic",
+		"lacuna.subscription.test.endpoint":                 "/messages",
+		"lacuna.subscription.test.dead-letter-subscription": "true",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if subscriptions[0].DeadLetterSubscription {
+		t.Errorf("expected dead-letter-subscription to be ignored")
+	}
+}
+
+func TestReferencedTopicsQualifiesTopicsInOtherProjects(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.topic.orders.project":                 "platform",
+		"lacuna.subscription.test.project":            "service",
+		"lacuna.subscription.test.topic":              "test-topic",
+		"lacuna.subscription.test.endpoint":           "/messages",
+		"lacuna.subscription.other.topic":             "projects/platform/topics/events",
+		"lacuna.subscription.other.endpoint":          "/events",
+		"lacuna.subscription.other.dead-letter-topic": "dead-letter-topic",
+	})
+
+	// act
+	references := referencedTopics(container, extractTopics(container), extractSubscriptions(container), "test")
+
+	// assert
+	for _, name := range []string{
+		"projects/platform/topics/orders",
+		"projects/service/topics/test-topic",
+		"projects/platform/topics/events",
+		"projects/test/topics/dead-letter-topic",
+	} {
+		if _, ok := references[name]; !ok {
+			t.Errorf("expected topic '%s' to be referenced, got %v", name, references)
+		}
+	}
+}
+
+func TestExtractSubscriptionsExtractsEndpointPortAndNetwork(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":    "test-topic",
+		"lacuna.subscription.test.endpoint": "/messages",
+		"lacuna.subscription.test.port":     "8080",
+		"lacuna.subscription.test.network":  "backend",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if subscriptions[0].EndpointPort != 8080 {
+		t.Errorf("expected port to be 8080, got %d", subscriptions[0].EndpointPort)
+	}
+
+	if subscriptions[0].EndpointNetwork != "backend" {
+		t.Errorf("expected network to be 'backend', got '%s'", subscriptions[0].EndpointNetwork)
+	}
+}
+
+func TestExtractSubscriptionsIgnoresPortOfEndpointURL(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{
+		"lacuna.subscription.test.topic":    "test-topic",
+		"lacuna.subscription.test.endpoint": "http://test/messages",
+		"lacuna.subscription.test.port":     "8080",
+	})
+
+	// act
+	subscriptions := extractSubscriptions(container)
+
+	// assert
+	if len(subscriptions) != 1 {
+		t.Fatalf("expected 1 subscription, got %d", len(subscriptions))
+	}
+
+	if subscriptions[0].EndpointPort != 0 {
+		t.Errorf("expected port to be ignored, got %d", subscriptions[0].EndpointPort)
+	}
+}
+
+func TestWaitsForHealthyAppliesToContainersWithHealthcheck(t *testing.T) {
+	// arrange
+	container := docker.NewContainer("1", map[string]string{})
+	container.Health = docker.HEALTH_STATUS_STARTING
+
+	optedOut := docker.NewContainer("2", map[string]string{"lacuna.wait-for-healthy": "false"})
+	optedOut.Health = docker.HEALTH_STATUS_STARTING
+
+	withoutHealthcheck := docker.NewContainer("3", map[string]string{"lacuna.wait-for-healthy": "true"})
+
+	// act
+	waits := waitsForHealthy(container)
+	optedOutWaits := waitsForHealthy(optedOut)
+	withoutHealthcheckWaits := waitsForHealthy(withoutHealthcheck)
+
+	// assert
+	if !waits {
+		t.Errorf("expected container with healthcheck to wait for becoming healthy")
+	}
+
+	if optedOutWaits {
+		t.Errorf("expected container which opted out not to wait for becoming healthy")
+	}
+
+	if withoutHealthcheckWaits {
+		t.Errorf("expected container without healthcheck not to wait for becoming healthy")
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aplr/lacuna/app"
+	"github.com/aplr/lacuna/pubsub"
+	"github.com/spf13/cobra"
+)
+
+var (
+	capturedCount   int
+	capturedTimeout time.Duration
+	capturedClear   bool
+	capturedOutput  string
+	capturedURL     string
+	capturedProject string
+)
+
+// capturedCmd represents the captured command
+var capturedCmd = &cobra.Command{
+	Use:   "captured <sink>",
+	Short: "Show messages captured by a sink.",
+	Long: `Show messages captured by a sink, which is a subscription with the
+endpoint lacuna://capture, identified by its subscription ID, i.e.
+<container>_<subscription>, and its project.
+
+With --count, waits until the sink captured at least the given number of
+messages, and fails if it did not within --timeout.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCaptured,
+}
+
+func runCaptured(cmd *cobra.Command, args []string) error {
+	printMessage, err := messagePrinter(capturedOutput)
+
+	if err != nil {
+		return err
+	}
+
+	config, err := app.GetConfig()
+
+	if err != nil {
+		return err
+	}
+
+	project := capturedProject
+
+	if project == "" {
+		project = config.PubSub.ProjectID
+	}
+
+	baseURL := capturedURL
+
+	if baseURL == "" {
+		baseURL = config.Proxy.URL
+	}
+
+	endpoint := fmt.Sprintf("%s/captures/projects/%s/subscriptions/%s", strings.TrimRight(baseURL, "/"), url.PathEscape(project), url.PathEscape(args[0]))
+
+	if capturedClear {
+		req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+
+		if err != nil {
+			return err
+		}
+
+		res, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			return err
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("proxy responded with %s", res.Status)
+		}
+
+		return nil
+	}
+
+	if cmd.Flags().Changed("count") {
+		endpoint += fmt.Sprintf("?count=%d&timeout=%s", capturedCount, capturedTimeout)
+	}
+
+	res, err := http.Get(endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusRequestTimeout {
+		message, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("proxy responded with %s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+
+	var messages []pubsub.Message
+
+	if err := json.NewDecoder(res.Body).Decode(&messages); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		printMessage(message)
+	}
+
+	if res.StatusCode == http.StatusRequestTimeout {
+		return fmt.Errorf("sink captured %d of %d messages within %s", len(messages), capturedCount, capturedTimeout)
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(capturedCmd)
+
+	capturedCmd.Flags().IntVar(&capturedCount, "count", 0, "wait until the sink captured at least the given number of messages")
+	capturedCmd.Flags().DurationVar(&capturedTimeout, "timeout", 30*time.Second, "maximum time to wait for messages")
+	capturedCmd.Flags().BoolVar(&capturedClear, "clear", false, "remove all messages captured by the sink")
+	capturedCmd.Flags().StringVarP(&capturedOutput, "output", "o", "pretty", "output format, one of 'pretty', 'json' or 'raw'")
+	capturedCmd.Flags().StringVar(&capturedURL, "url", "", "url of the lacuna proxy, defaults to the configured proxy url")
+	capturedCmd.Flags().StringVar(&capturedProject, "project", "", "project of the sink, defaults to the configured project")
+}
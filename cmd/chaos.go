@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aplr/lacuna/app"
+	"github.com/aplr/lacuna/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chaosErrorRate     float64
+	chaosLatency       time.Duration
+	chaosDuplicateRate float64
+	chaosDropRate      float64
+	chaosReset         bool
+	chaosProject       string
+	chaosURL           string
+)
+
+// chaosCmd represents the chaos command
+var chaosCmd = &cobra.Command{
+	Use:   "chaos <container> <subscription>",
+	Short: "Show or change the chaos options of a subscription.",
+	Long: `Show or change the faults injected into push requests of a subscription,
+while lacuna is running. Options which are not given are left unchanged,
+--reset removes all faults. Without any option, the current options are shown.
+
+Only subscriptions relayed through the proxy are known, i.e. subscriptions
+with the proxy label or a chaos label, or all push subscriptions if the proxy
+is enabled.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runChaos,
+}
+
+func runChaos(cmd *cobra.Command, args []string) error {
+	config, err := app.GetConfig()
+
+	if err != nil {
+		return err
+	}
+
+	project := chaosProject
+
+	if project == "" {
+		project = config.PubSub.ProjectID
+	}
+
+	baseURL := chaosURL
+
+	if baseURL == "" {
+		baseURL = config.Proxy.URL
+	}
+
+	subscription := subscriptionFromArgs(args[0], args[1], project)
+	url := fmt.Sprintf("%s/chaos/projects/%s/subscriptions/%s", strings.TrimRight(baseURL, "/"), project, subscription.GetSubscriptionID())
+
+	chaos, err := requestChaos(http.MethodGet, url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+
+	if chaosReset {
+		chaos = proxy.Chaos{}
+	}
+	if flags.Changed("error-rate") {
+		chaos.ErrorRate = chaosErrorRate
+	}
+	if flags.Changed("latency") {
+		chaos.Latency = chaosLatency
+	}
+	if flags.Changed("duplicate-rate") {
+		chaos.DuplicateRate = chaosDuplicateRate
+	}
+	if flags.Changed("drop-rate") {
+		chaos.DropRate = chaosDropRate
+	}
+
+	for _, name := range []string{"reset", "error-rate", "latency", "duplicate-rate", "drop-rate"} {
+		if flags.Changed(name) {
+			chaos, err = requestChaos(http.MethodPut, url, &chaos)
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("error-rate:     %v\nlatency:        %s\nduplicate-rate: %v\ndrop-rate:      %v\n", chaos.ErrorRate, chaos.Latency, chaos.DuplicateRate, chaos.DropRate)
+
+	return nil
+}
+
+// requestChaos sends a request to the chaos API of the proxy,
+// and returns the chaos options of the subscription.
+func requestChaos(method string, url string, chaos *proxy.Chaos) (proxy.Chaos, error) {
+	var body io.Reader
+
+	if chaos != nil {
+		b, err := json.Marshal(chaos)
+
+		if err != nil {
+			return proxy.Chaos{}, err
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+
+	if err != nil {
+		return proxy.Chaos{}, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return proxy.Chaos{}, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(res.Body)
+		return proxy.Chaos{}, fmt.Errorf("proxy responded with %s: %s", res.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result proxy.Chaos
+
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return proxy.Chaos{}, err
+	}
+
+	return result, nil
+}
+
+func init() {
+	rootCmd.AddCommand(chaosCmd)
+
+	chaosCmd.Flags().Float64Var(&chaosErrorRate, "error-rate", 0, "share of push requests failed without relaying them")
+	chaosCmd.Flags().DurationVar(&chaosLatency, "latency", 0, "delay before relaying push requests")
+	chaosCmd.Flags().Float64Var(&chaosDuplicateRate, "duplicate-rate", 0, "share of push requests relayed twice")
+	chaosCmd.Flags().Float64Var(&chaosDropRate, "drop-rate", 0, "share of push responses dropped after relaying the request")
+	chaosCmd.Flags().BoolVar(&chaosReset, "reset", false, "remove all faults")
+	chaosCmd.Flags().StringVar(&chaosProject, "project", "", "project of the subscription, defaults to the configured project")
+	chaosCmd.Flags().StringVar(&chaosURL, "url", "", "url of the lacuna proxy, defaults to the configured proxy url")
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	publishFile        string
+	publishAttributes  []string
+	publishOrderingKey string
+	publishJSONL       bool
+	publishProject     string
+)
+
+// publishCmd represents the publish command
+var publishCmd = &cobra.Command{
+	Use:   "publish <topic> [payload]",
+	Short: "Publish messages to a topic.",
+	Long: `Publish messages to a topic, creating the topic if it does not exist.
+
+The payload is read from the argument, from the file given with --file, or
+from stdin. With --jsonl, each line of the input is published as a separate
+message of the form {"data": ..., "attributes": {...}, "ordering_key": ...},
+where data is either a string or any JSON value.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runPublish,
+}
+
+func runPublish(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	attributes, err := parseKeyValues("attribute", publishAttributes)
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := readPayload(args[1:], publishFile)
+
+	if err != nil {
+		return err
+	}
+
+	var messages []pubsub.Message
+
+	if publishJSONL {
+		if messages, err = parseMessages(payload); err != nil {
+			return err
+		}
+	} else {
+		messages = []pubsub.Message{{Data: payload}}
+	}
+
+	// flags apply to all messages, unless set by the message itself
+	for i := range messages {
+		if messages[i].OrderingKey == "" {
+			messages[i].OrderingKey = publishOrderingKey
+		}
+
+		for key, value := range attributes {
+			if messages[i].Attributes == nil {
+				messages[i].Attributes = make(map[string]string)
+			}
+
+			if _, ok := messages[i].Attributes[key]; !ok {
+				messages[i].Attributes[key] = value
+			}
+		}
+	}
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	topic := pubsub.Topic{
+		Name:    args[0],
+		Project: publishProject,
+		Labels:  map[string]string{pubsub.LABEL_MANAGED_BY: pubsub.MANAGED_BY_LACUNA},
+	}
+
+	ids, err := ps.Publish(ctx, topic, messages)
+
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("published %d messages to topic %s", len(ids), args[0])
+
+	return nil
+}
+
+// readPayload reads the payload from the arguments, the given file,
+// or stdin if neither is given or the file is '-'.
+func readPayload(args []string, file string) ([]byte, error) {
+	if len(args) > 0 {
+		if file != "" {
+			return nil, fmt.Errorf("payload must be given either as argument or as file")
+		}
+
+		return []byte(args[0]), nil
+	}
+
+	if file != "" && file != "-" {
+		return os.ReadFile(file)
+	}
+
+	return io.ReadAll(os.Stdin)
+}
+
+// parseMessages parses messages from JSON lines, skipping empty lines.
+func parseMessages(payload []byte) ([]pubsub.Message, error) {
+	messages := make([]pubsub.Message, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var message pubsub.Message
+
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, fmt.Errorf("invalid message on line %d: %w", line, err)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, scanner.Err()
+}
+
+// parseKeyValues parses flag values given in the format 'key=value'.
+func parseKeyValues(kind string, values []string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for _, pair := range values {
+		key, value, ok := strings.Cut(pair, "=")
+
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s: %s, must be in the format 'key=value'", kind, pair)
+		}
+
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+
+	publishCmd.Flags().StringVarP(&publishFile, "file", "f", "", "file to read the payload from, '-' for stdin")
+	publishCmd.Flags().StringArrayVar(&publishAttributes, "attr", nil, "message attribute in the format 'key=value', can be repeated")
+	publishCmd.Flags().StringVar(&publishOrderingKey, "ordering-key", "", "ordering key of the messages")
+	publishCmd.Flags().BoolVar(&publishJSONL, "jsonl", false, "publish each line of the payload as a separate message")
+	publishCmd.Flags().StringVar(&publishProject, "project", "", "project of the topic, defaults to the configured project")
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aplr/lacuna/app"
+	"github.com/aplr/lacuna/pubsub"
+)
+
+// newPubSub creates a pubsub client from the lacuna configuration,
+// for commands that talk to the emulator directly.
+func newPubSub(ctx context.Context) (pubsub.PubSub, error) {
+	config, err := app.GetConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := pubsub.NewPubSub(ctx, config.PubSub)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// subscriptionFromArgs resolves the subscription of a container
+// the same way the daemon derives subscription IDs from labels.
+func subscriptionFromArgs(container string, name string, project string) pubsub.Subscription {
+	return pubsub.Subscription{
+		Service: container,
+		Name:    strings.ToLower(name),
+		Project: project,
+	}
+}
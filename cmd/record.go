@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordTopics   []string
+	recordOutput   string
+	recordDuration time.Duration
+	recordProject  string
+)
+
+// recordedMessage is a single line of a recording. The offset is the time
+// the message was published at, relative to the start of the recording.
+type recordedMessage struct {
+	Topic   string         `json:"topic"`
+	Offset  string         `json:"offset"`
+	Message pubsub.Message `json:"message"`
+}
+
+// recordCmd represents the record command
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record messages published to topics.",
+	Long: `Record messages published to topics, until interrupted or the given
+duration elapsed. Messages are written as JSON lines, which can be replayed
+using 'lacuna replay'.`,
+	Args: cobra.NoArgs,
+	RunE: runRecord,
+}
+
+func runRecord(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if recordDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, recordDuration)
+		defer cancel()
+	}
+
+	var out io.Writer = os.Stdout
+
+	if recordOutput != "" && recordOutput != "-" {
+		file, err := os.Create(recordOutput)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		out = file
+	}
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := deleteStaleTailSubscriptions(ctx, ps, recordProject); err != nil {
+		return err
+	}
+
+	subscriptions := make(map[string]pubsub.Subscription)
+
+	for _, topic := range recordTopics {
+		subscription, remove, err := createTailSubscription(ctx, ps, pubsub.Topic{Name: topic, Project: recordProject})
+
+		if err != nil {
+			return err
+		}
+
+		defer remove()
+
+		subscriptions[topic] = subscription
+	}
+
+	start := time.Now()
+	encoder := json.NewEncoder(out)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	errs := make(chan error, len(subscriptions))
+
+	log.Infof("recording %d topics", len(subscriptions))
+
+	for topic, subscription := range subscriptions {
+		wg.Add(1)
+
+		go func(topic string, subscription pubsub.Subscription) {
+			defer wg.Done()
+
+			err := ps.Receive(ctx, subscription, func(message pubsub.Message) {
+				offset := message.PublishTime.Sub(start)
+
+				if offset < 0 {
+					offset = 0
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				err := encoder.Encode(recordedMessage{
+					Topic:   topic,
+					Offset:  offset.String(),
+					Message: message,
+				})
+
+				if err != nil {
+					log.WithError(err).Error("failed to write message")
+				}
+			})
+
+			if err != nil && !errors.Is(err, context.Canceled) {
+				errs <- fmt.Errorf("failed to record topic %s: %w", topic, err)
+			}
+		}(topic, subscription)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+func init() {
+	rootCmd.AddCommand(recordCmd)
+
+	recordCmd.Flags().StringArrayVarP(&recordTopics, "topic", "t", nil, "topic to record, can be repeated")
+	recordCmd.Flags().StringVarP(&recordOutput, "output", "o", "", "file to write the recording to, defaults to stdout")
+	recordCmd.Flags().DurationVar(&recordDuration, "duration", 0, "stop recording after the given duration")
+	recordCmd.Flags().StringVar(&recordProject, "project", "", "project of the topics, defaults to the configured project")
+	recordCmd.MarkFlagRequired("topic")
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayFast    bool
+	replayTopics  []string
+	replayProject string
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Replay recorded messages.",
+	Long: `Replay messages recorded using 'lacuna record'.
+
+Messages are published with the same timing as recorded, unless --fast is
+given. Topics can be remapped using --topic old=new.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	topics, err := parseKeyValues("topic mapping", replayTopics)
+
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		in = file
+	}
+
+	recording, err := readRecording(in)
+
+	if err != nil {
+		return err
+	}
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	for i := 0; i < len(recording); {
+		// publish consecutive messages of the same topic at once
+		batch := []pubsub.Message{recording[i].message}
+		topic := recording[i].topic
+		offset := recording[i].offset
+
+		for i++; i < len(recording) && recording[i].topic == topic; i++ {
+			if !replayFast && recording[i].offset != offset {
+				break
+			}
+
+			batch = append(batch, recording[i].message)
+		}
+
+		if !replayFast {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Until(start.Add(offset))):
+			}
+		}
+
+		if mapped, ok := topics[topic]; ok {
+			topic = mapped
+		}
+
+		if _, err := ps.Publish(ctx, pubsub.Topic{Name: topic, Project: replayProject}, batch); err != nil {
+			return err
+		}
+
+		log.Debugf("replayed %d messages to topic %s", len(batch), topic)
+	}
+
+	log.Infof("replayed %d messages", len(recording))
+
+	return nil
+}
+
+type replayedMessage struct {
+	topic   string
+	offset  time.Duration
+	message pubsub.Message
+}
+
+// readRecording reads a recording, ordered by the offset of its messages.
+func readRecording(in io.Reader) ([]replayedMessage, error) {
+	recording := make([]replayedMessage, 0)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var recorded recordedMessage
+
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return nil, fmt.Errorf("invalid message on line %d: %w", line, err)
+		}
+
+		offset, err := time.ParseDuration(recorded.Offset)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset on line %d: %w", line, err)
+		}
+
+		recording = append(recording, replayedMessage{
+			topic:  recorded.Topic,
+			offset: offset,
+			// only the content of the message is replayed
+			message: pubsub.Message{
+				Data:        recorded.Message.Data,
+				Attributes:  recorded.Message.Attributes,
+				OrderingKey: recorded.Message.OrderingKey,
+			},
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// messages of different topics may have been received out of order
+	sort.SliceStable(recording, func(i, j int) bool {
+		return recording[i].offset < recording[j].offset
+	})
+
+	return recording, nil
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().BoolVar(&replayFast, "fast", false, "publish messages as fast as possible instead of in real time")
+	replayCmd.Flags().StringArrayVarP(&replayTopics, "topic", "t", nil, "topic mapping in the format 'recorded=target', can be repeated")
+	replayCmd.Flags().StringVar(&replayProject, "project", "", "project of the topics, defaults to the configured project")
+}
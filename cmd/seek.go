@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	seekTo      string
+	seekProject string
+)
+
+// seekCmd represents the seek command
+var seekCmd = &cobra.Command{
+	Use:   "seek <container> <subscription>",
+	Short: "Seek a subscription to a point in time or a snapshot.",
+	Long: `Seek a subscription to a point in time or a snapshot.
+
+The target given with --to is either a duration relative to now (e.g. 10m),
+an RFC 3339 timestamp (e.g. 2023-06-01T12:00:00Z) or the name of a snapshot.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSeek,
+}
+
+func runSeek(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	subscription := subscriptionFromArgs(args[0], args[1], seekProject)
+
+	if to, ok := pubsub.ParseSeekTime(seekTo, time.Now()); ok {
+		if err := ps.SeekToTime(ctx, subscription, to); err != nil {
+			return err
+		}
+
+		log.Infof("subscription %s seeked to %s", subscription.GetSubscriptionID(), to.Format(time.RFC3339))
+
+		return nil
+	}
+
+	if err := ps.SeekToSnapshot(ctx, subscription, seekTo); err != nil {
+		return err
+	}
+
+	log.Infof("subscription %s seeked to snapshot %s", subscription.GetSubscriptionID(), seekTo)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(seekCmd)
+
+	seekCmd.Flags().StringVar(&seekTo, "to", "", "time, duration or snapshot to seek to")
+	seekCmd.Flags().StringVar(&seekProject, "project", "", "project of the subscription, defaults to the configured project")
+	seekCmd.MarkFlagRequired("to")
+}
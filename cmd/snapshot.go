@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var snapshotProject string
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage subscription snapshots.",
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <container> <subscription> <snapshot>",
+	Short: "Create a snapshot of a subscription.",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runSnapshotCreate,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots.",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotList,
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <snapshot>",
+	Short: "Delete a snapshot.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotDelete,
+}
+
+func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	subscription := subscriptionFromArgs(args[0], args[1], snapshotProject)
+
+	snapshot, err := ps.CreateSnapshot(ctx, subscription, args[2])
+
+	if err != nil {
+		return err
+	}
+
+	log.Infof("snapshot %s of subscription %s created", snapshot.Name, subscription.GetSubscriptionID())
+
+	return nil
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := ps.ListSnapshots(ctx, snapshotProject)
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tTOPIC\tEXPIRES")
+
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", snapshot.Name, snapshot.Topic, snapshot.Expiration.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+func runSnapshotDelete(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ps.DeleteSnapshot(ctx, pubsub.Snapshot{Name: args[0], Project: snapshotProject}); err != nil {
+		return err
+	}
+
+	log.Infof("snapshot %s deleted", args[0])
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotDeleteCmd)
+
+	snapshotCmd.PersistentFlags().StringVar(&snapshotProject, "project", "", "project of the snapshots, defaults to the configured project")
+}
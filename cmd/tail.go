@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tailOutput  string
+	tailProject string
+)
+
+// tailCmd represents the tail command
+var tailCmd = &cobra.Command{
+	Use:   "tail <topic>",
+	Short: "Stream messages published to a topic.",
+	Long: `Stream messages published to a topic.
+
+A temporary pull subscription is created on the topic, so consumers of the
+topic are not affected. The subscription is deleted once tail is stopped.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTail,
+}
+
+func runTail(cmd *cobra.Command, args []string) error {
+	printMessage, err := messagePrinter(tailOutput)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ps, err := newPubSub(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := deleteStaleTailSubscriptions(ctx, ps, tailProject); err != nil {
+		return err
+	}
+
+	subscription, remove, err := createTailSubscription(ctx, ps, pubsub.Topic{Name: args[0], Project: tailProject})
+
+	if err != nil {
+		return err
+	}
+
+	defer remove()
+
+	log.Debugf("tailing topic %s", args[0])
+
+	var mu sync.Mutex
+
+	err = ps.Receive(ctx, subscription, func(message pubsub.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		printMessage(message)
+	})
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return nil
+}
+
+// deleteStaleTailSubscriptions removes the temporary subscriptions left
+// behind by tail processes on this host which crashed.
+func deleteStaleTailSubscriptions(ctx context.Context, ps pubsub.PubSub, projectID string) error {
+	host, err := os.Hostname()
+
+	if err != nil {
+		return err
+	}
+
+	if err := ps.DeleteStaleTailSubscriptions(ctx, projectID, host, processAlive); err != nil {
+		log.WithError(err).Warn("failed to remove stale tail subscriptions")
+	}
+
+	return nil
+}
+
+// createTailSubscription creates a temporary subscription on the topic, and
+// returns a function removing it again, which must be called on exit.
+func createTailSubscription(ctx context.Context, ps pubsub.PubSub, topic pubsub.Topic) (pubsub.Subscription, func(), error) {
+	host, err := os.Hostname()
+
+	if err != nil {
+		return pubsub.Subscription{}, nil, err
+	}
+
+	subscription := pubsub.TailSubscription(topic, host, os.Getpid())
+
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		return pubsub.Subscription{}, nil, err
+	}
+
+	remove := func() {
+		// the command context is usually cancelled at this point
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := ps.DeleteSubscription(ctx, subscription); err != nil {
+			log.WithError(err).Error("failed to remove tail subscription")
+		}
+	}
+
+	return subscription, remove, nil
+}
+
+// messagePrinter returns a function printing messages in the given format,
+// which is either pretty, json or raw.
+func messagePrinter(format string) (func(pubsub.Message), error) {
+	switch format {
+	case "pretty":
+		return printPrettyMessage, nil
+	case "json":
+		return printJSONMessage, nil
+	case "raw":
+		return func(message pubsub.Message) {
+			fmt.Println(string(message.Data))
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format: %s, must be one of 'pretty', 'json' or 'raw'", format)
+	}
+}
+
+func printPrettyMessage(message pubsub.Message) {
+	fmt.Printf("--- %s  id=%s", message.PublishTime.Format(time.RFC3339Nano), message.ID)
+
+	if message.OrderingKey != "" {
+		fmt.Printf("  ordering-key=%s", message.OrderingKey)
+	}
+
+	fmt.Println()
+
+	keys := make([]string, 0, len(message.Attributes))
+
+	for key := range message.Attributes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s: %s\n", key, message.Attributes[key])
+	}
+
+	fmt.Println(strings.TrimRight(string(message.Data), "\n"))
+}
+
+func printJSONMessage(message pubsub.Message) {
+	b, err := json.Marshal(message)
+
+	if err != nil {
+		log.WithError(err).Error("failed to encode message")
+		return
+	}
+
+	fmt.Println(string(b))
+}
+
+// processAlive reports whether a process with the given ID is running.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+
+	if err != nil {
+		return false
+	}
+
+	err = process.Signal(syscall.Signal(0))
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+
+	tailCmd.Flags().StringVarP(&tailOutput, "output", "o", "pretty", "output format, one of 'pretty', 'json' or 'raw'")
+	tailCmd.Flags().StringVar(&tailProject, "project", "", "project of the topic, defaults to the configured project")
+}
@@ -11,8 +11,9 @@ import (
 type mockDocker struct {
 	client.APIClient
 
-	containerList func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
-	events        func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+	containerList    func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	events           func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+	containerInspect func(ctx context.Context, containerID string) (types.ContainerJSON, error)
 }
 
 func (d *mockDocker) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
@@ -31,4 +32,12 @@ func (d *mockDocker) Events(ctx context.Context, options types.EventsOptions) (<
 	return d.events(ctx, options)
 }
 
+func (d *mockDocker) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if d.containerInspect == nil {
+		panic("no mock function provided")
+	}
+
+	return d.containerInspect(ctx, containerID)
+}
+
 var _ client.APIClient = client.APIClient(&mockDocker{})
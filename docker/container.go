@@ -2,15 +2,46 @@ package docker
 
 import "strings"
 
+type HealthStatus string
+
+const (
+	// The container has no healthcheck
+	HEALTH_STATUS_NONE      HealthStatus = ""
+	HEALTH_STATUS_STARTING  HealthStatus = "starting"
+	HEALTH_STATUS_HEALTHY   HealthStatus = "healthy"
+	HEALTH_STATUS_UNHEALTHY HealthStatus = "unhealthy"
+)
+
 type Container struct {
 	ID     string
 	Labels map[string]string
+	// Networks the container is connected to by name, only set by Inspect
+	Networks map[string]Network
+	// Ports exposed by the container, only set by Inspect
+	Ports []Port
+	// Environment variables of the container, only set by Inspect
+	Env map[string]string
+	// Health status of the container, only set by Inspect
+	Health HealthStatus
+	// Whether the container is paused, only set by Inspect
+	Paused bool
 }
 
 func NewContainer(ID string, Labels map[string]string) Container {
 	return Container{ID: ID, Labels: Labels}
 }
 
+// ComposeProject returns the name of the compose project
+// the container belongs to, if it was started by compose.
+func (container *Container) ComposeProject() string {
+	return container.Labels["com.docker.compose.project"]
+}
+
+// HasHealthcheck reports whether the container reports its health.
+func (container *Container) HasHealthcheck() bool {
+	return container.Health != HEALTH_STATUS_NONE
+}
+
 func (container *Container) Name() string {
 	if _, ok := container.Labels["com.docker.compose.project"]; ok {
 		return strings.Join([]string{
@@ -46,3 +46,96 @@ func TestExtractServiceNameReturnsId(t *testing.T) {
 		t.Errorf("expected service name to be '1', got '%s'", serviceName)
 	}
 }
+
+func networkedContainer() Container {
+	container := NewContainer("1", map[string]string{
+		"com.docker.compose.service": "api",
+		"com.docker.compose.project": "project",
+	})
+	container.Networks = map[string]Network{
+		"project_default": {Name: "project_default", IP: "172.18.0.2", Aliases: []string{"project-api-1", "api"}},
+		"project_backend": {Name: "project_backend", IP: "172.19.0.2"},
+	}
+	container.Ports = []Port{
+		{Port: 8080, Protocol: "tcp", HostPorts: []int{32768}},
+	}
+
+	return container
+}
+
+func TestNetworkAddressPrefersComposeServiceAlias(t *testing.T) {
+	// arrange
+	container := networkedContainer()
+
+	// act
+	address, err := container.NetworkAddress("default", 0)
+
+	// assert
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if address != "api:8080" {
+		t.Errorf("expected address to be 'api:8080', got '%s'", address)
+	}
+}
+
+func TestNetworkAddressFallsBackToIP(t *testing.T) {
+	// arrange
+	container := networkedContainer()
+
+	// act
+	address, err := container.NetworkAddress("project_backend", 3000)
+
+	// assert
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if address != "172.19.0.2:3000" {
+		t.Errorf("expected address to be '172.19.0.2:3000', got '%s'", address)
+	}
+}
+
+func TestNetworkAddressFailsForUnknownNetwork(t *testing.T) {
+	// arrange
+	container := networkedContainer()
+
+	// act
+	_, err := container.NetworkAddress("frontend", 0)
+
+	// assert
+	if err == nil {
+		t.Errorf("expected error for unknown network")
+	}
+}
+
+func TestPublishedPortReturnsHostPort(t *testing.T) {
+	// arrange
+	container := networkedContainer()
+
+	// act
+	port, err := container.PublishedPort(0)
+
+	// assert
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if port != 32768 {
+		t.Errorf("expected port to be 32768, got %d", port)
+	}
+}
+
+func TestPublishedPortFailsForUnpublishedPort(t *testing.T) {
+	// arrange
+	container := networkedContainer()
+
+	// act
+	_, err := container.PublishedPort(9090)
+
+	// assert
+	if err == nil {
+		t.Errorf("expected error for unpublished port")
+	}
+}
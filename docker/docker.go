@@ -12,12 +12,20 @@ import (
 )
 
 var (
-	startEventName = "start" // TODO: evaluate event
-	stopEventName  = "stop"  // TODO: evaluate event
+	startEventName      = "start" // TODO: evaluate event
+	stopEventName       = "stop"  // TODO: evaluate event
+	pauseEventName      = "pause"
+	unpauseEventName    = "unpause"
+	connectEventName    = "connect"
+	disconnectEventName = "disconnect"
+	// actions of health events carry the status, i.e. 'health_status: healthy'
+	healthStatusEventName = "health_status"
 )
 
 type Docker interface {
 	Run(ctx context.Context) (<-chan Event, <-chan error)
+	Containers(ctx context.Context) ([]Container, error)
+	Inspect(ctx context.Context, containerID string) (Container, error)
 }
 
 var _ = Docker(&dockerImpl{})
@@ -72,19 +80,46 @@ func (docker *dockerImpl) Run(ctx context.Context) (<-chan Event, <-chan error)
 	return messages, errs
 }
 
-func (docker *dockerImpl) handleInitialContainers(
-	ctx context.Context,
-	out chan Event,
-) error {
-	containers, _ := docker.cli.ContainerList(ctx, types.ContainerListOptions{
+// Containers returns all running containers which have lacuna enabled.
+func (docker *dockerImpl) Containers(ctx context.Context) ([]Container, error) {
+	list, err := docker.cli.ContainerList(ctx, types.ContainerListOptions{
 		Filters: filters.NewArgs(
 			filters.KeyValuePair{Key: "label", Value: docker.filterLabel()},
 		),
 	})
 
-	for _, c := range containers {
-		container := NewContainer(c.ID, c.Labels)
-		docker.handleContainer(ctx, EVENT_TYPE_START, container, out)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, 0, len(list))
+
+	for _, c := range list {
+		containers = append(containers, NewContainer(c.ID, c.Labels))
+	}
+
+	return containers, nil
+}
+
+// Inspect returns the container along with its health, networks, ports and environment.
+func (docker *dockerImpl) Inspect(ctx context.Context, containerID string) (Container, error) {
+	info, err := docker.cli.ContainerInspect(ctx, containerID)
+
+	if err != nil {
+		return Container{}, err
+	}
+
+	return mapContainer(info), nil
+}
+
+func (docker *dockerImpl) handleInitialContainers(
+	ctx context.Context,
+	out chan Event,
+) error {
+	containers, _ := docker.Containers(ctx)
+
+	for _, container := range containers {
+		docker.handleContainer(ctx, EVENT_TYPE_START, docker.inspect(ctx, container), out)
 	}
 
 	return nil
@@ -100,6 +135,19 @@ func (docker *dockerImpl) listenForContainerChanges(ctx context.Context, message
 			filters.KeyValuePair{Key: "label", Value: docker.filterLabel()},
 			filters.KeyValuePair{Key: "event", Value: startEventName},
 			filters.KeyValuePair{Key: "event", Value: stopEventName},
+			filters.KeyValuePair{Key: "event", Value: healthStatusEventName},
+			filters.KeyValuePair{Key: "event", Value: pauseEventName},
+			filters.KeyValuePair{Key: "event", Value: unpauseEventName},
+		),
+	})
+
+	// network events carry the labels of the network, not the container,
+	// so containers are filtered when handling the events instead
+	networkMsgChannel, networkErrChannel := docker.cli.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.KeyValuePair{Key: "type", Value: "network"},
+			filters.KeyValuePair{Key: "event", Value: connectEventName},
+			filters.KeyValuePair{Key: "event", Value: disconnectEventName},
 		),
 	})
 
@@ -112,10 +160,15 @@ func (docker *dockerImpl) listenForContainerChanges(ctx context.Context, message
 		case msg := <-msgChannel:
 			// Publish messages to channel
 			docker.handleMessage(ctx, msg, messages)
+		case msg := <-networkMsgChannel:
+			docker.handleNetworkMessage(ctx, msg, messages)
 		case err := <-errChannel:
 			// Log errors and silently return from the listener
 			docker.handleError(ctx, err, errs)
 			return
+		case err := <-networkErrChannel:
+			docker.handleError(ctx, err, errs)
+			return
 		}
 	}
 }
@@ -125,16 +178,73 @@ func (docker *dockerImpl) handleMessage(
 	message events.Message,
 	out chan Event,
 ) {
-	eventType := mapEventType(message.Action)
+	eventType, ok := mapEventType(message.Action)
+
+	if !ok {
+		docker.log.WithField("action", message.Action).Debug("skipping unsupported event")
+		return
+	}
 
 	container := NewContainer(
 		message.Actor.ID,
 		message.Actor.Attributes,
 	)
 
+	// the state, networks and environment of containers
+	// are only available until they stop
+	if eventType != EVENT_TYPE_STOP {
+		container = docker.inspect(ctx, container)
+	}
+
 	docker.handleContainer(ctx, eventType, container, out)
 }
 
+// inspect returns the inspected container, or the
+// container itself if it could not be inspected.
+func (docker *dockerImpl) inspect(ctx context.Context, container Container) Container {
+	inspected, err := docker.Inspect(ctx, container.ID)
+
+	if err != nil {
+		docker.log.WithError(err).WithField("container", container.Name()).Warn("error inspecting container")
+		return container
+	}
+
+	return inspected
+}
+
+// handleNetworkMessage publishes a network event for running containers
+// which have lacuna enabled, once they are connected to or disconnected
+// from a network. Containers which are starting or stopping are skipped,
+// as they are handled by their start and stop events.
+func (docker *dockerImpl) handleNetworkMessage(
+	ctx context.Context,
+	message events.Message,
+	out chan Event,
+) {
+	if message.Action != connectEventName && message.Action != disconnectEventName {
+		return
+	}
+
+	log := docker.log.WithField("network", message.Actor.Attributes["name"]).WithField("container_id", message.Actor.Attributes["container"])
+
+	info, err := docker.cli.ContainerInspect(ctx, message.Actor.Attributes["container"])
+
+	if err != nil {
+		log.WithError(err).Warn("error inspecting container")
+		return
+	}
+
+	if info.Config == nil || info.Config.Labels[docker.labelPrefix+".enabled"] != "true" {
+		return
+	}
+
+	if info.ContainerJSONBase == nil || info.State == nil || !info.State.Running {
+		return
+	}
+
+	docker.handleContainer(ctx, EVENT_TYPE_NETWORK, mapContainer(info), out)
+}
+
 func (docker *dockerImpl) handleContainer(
 	ctx context.Context,
 	eventType EventType,
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 )
 
 // TODO: could not work as docker might not be installed in the test execution environment
@@ -38,6 +42,9 @@ func TestRunReturnsInitialContainers(t *testing.T) {
 		containerList: func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
 			return []types.Container{{ID: "1"}}, nil
 		},
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectedContainer(true), nil
+		},
 		events: func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
 			msgs := make(chan events.Message)
 			errs := make(chan error)
@@ -78,6 +85,9 @@ func TestRunHandlesMessage(t *testing.T) {
 			}()
 			return msgs, errs
 		},
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectedContainer(true), nil
+		},
 	}
 
 	docker := NewDockerWithClient(cli, "lacuna")
@@ -159,3 +169,218 @@ func TestRunHandlesError(t *testing.T) {
 		return
 	}
 }
+
+func TestContainersReturnsRunningContainers(t *testing.T) {
+	cli := &mockDocker{
+		containerList: func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+			return []types.Container{{ID: "1", Labels: map[string]string{"lacuna.enabled": "true"}}}, nil
+		},
+	}
+
+	docker := NewDockerWithClient(cli, "lacuna")
+
+	containers, err := docker.Containers(context.Background())
+
+	if err != nil {
+		t.Errorf("Containers() returned error: %v", err)
+	}
+
+	if len(containers) != 1 || containers[0].ID != "1" {
+		t.Errorf("expected container with id '1', got %v", containers)
+	}
+}
+
+func inspectedContainer(running bool) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID: "1",
+			State: &types.ContainerState{
+				Running: running,
+				Paused:  true,
+				Health:  &types.Health{Status: types.Healthy},
+			},
+		},
+		Config: &container.Config{
+			Labels: map[string]string{"lacuna.enabled": "true"},
+			Env:    []string{"PORT=8080", "EMPTY="},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: nat.PortMap{
+					"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}, {HostIP: "::", HostPort: "32768"}},
+					"9090/tcp": nil,
+				},
+			},
+			Networks: map[string]*network.EndpointSettings{
+				"project_default": {IPAddress: "172.18.0.2", Aliases: []string{"api", "1"}},
+			},
+		},
+	}
+}
+
+func TestInspectReturnsNetworksAndPorts(t *testing.T) {
+	cli := &mockDocker{
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectedContainer(true), nil
+		},
+	}
+
+	docker := NewDockerWithClient(cli, "lacuna")
+
+	container, err := docker.Inspect(context.Background(), "1")
+
+	if err != nil {
+		t.Errorf("Inspect() returned error: %v", err)
+	}
+
+	if container.Networks["project_default"].IP != "172.18.0.2" {
+		t.Errorf("expected ip to be '172.18.0.2', got '%s'", container.Networks["project_default"].IP)
+	}
+
+	if container.Health != HEALTH_STATUS_HEALTHY {
+		t.Errorf("expected health to be '%s', got '%s'", HEALTH_STATUS_HEALTHY, container.Health)
+	}
+
+	if !container.Paused {
+		t.Errorf("expected container to be paused")
+	}
+
+	if container.Env["PORT"] != "8080" {
+		t.Errorf("expected env PORT to be '8080', got '%s'", container.Env["PORT"])
+	}
+
+	if len(container.Ports) != 2 || container.Ports[0].Port != 8080 || container.Ports[1].Port != 9090 {
+		t.Fatalf("expected ports 8080 and 9090, got %v", container.Ports)
+	}
+
+	if len(container.Ports[0].HostPorts) != 1 || container.Ports[0].HostPorts[0] != 32768 {
+		t.Errorf("expected port 8080 to be published on 32768, got %v", container.Ports[0].HostPorts)
+	}
+}
+
+func TestRunHandlesNetworkMessage(t *testing.T) {
+	cli := &mockDocker{
+		containerList: func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+			return []types.Container{}, nil
+		},
+		events: func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+			msgs := make(chan events.Message)
+			errs := make(chan error)
+			if options.Filters.ExactMatch("type", "network") {
+				go func() {
+					msgs <- events.Message{
+						Action: "connect",
+						Actor:  events.Actor{ID: "n1", Attributes: map[string]string{"container": "1", "name": "project_default"}},
+					}
+				}()
+			}
+			return msgs, errs
+		},
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectedContainer(true), nil
+		},
+	}
+
+	docker := NewDockerWithClient(cli, "lacuna")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, errs := docker.Run(ctx)
+
+	select {
+	case event := <-events:
+		if event.Type != EVENT_TYPE_NETWORK {
+			t.Errorf("expected event type to be '%s', got '%s'", EVENT_TYPE_NETWORK, event.Type)
+		}
+		if _, ok := event.Container.Networks["project_default"]; !ok {
+			t.Errorf("expected container to be inspected, got %v", event.Container)
+		}
+	case err := <-errs:
+		t.Errorf("Run() returned error: %v", err)
+	}
+}
+
+func TestRunSkipsNetworkMessageOfStoppedContainer(t *testing.T) {
+	inspected := make(chan struct{})
+	cli := &mockDocker{
+		containerList: func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+			return []types.Container{}, nil
+		},
+		events: func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+			msgs := make(chan events.Message)
+			errs := make(chan error)
+			if options.Filters.ExactMatch("type", "network") {
+				go func() {
+					msgs <- events.Message{
+						Action: "disconnect",
+						Actor:  events.Actor{ID: "n1", Attributes: map[string]string{"container": "1"}},
+					}
+				}()
+			}
+			return msgs, errs
+		},
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			defer close(inspected)
+			return inspectedContainer(false), nil
+		},
+	}
+
+	docker := NewDockerWithClient(cli, "lacuna")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, _ := docker.Run(ctx)
+
+	<-inspected
+
+	select {
+	case evt := <-events:
+		t.Errorf("Run() returned event: %v", evt)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunHandlesHealthStatusMessage(t *testing.T) {
+	cli := &mockDocker{
+		containerList: func(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+			return []types.Container{}, nil
+		},
+		events: func(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+			msgs := make(chan events.Message)
+			errs := make(chan error)
+			if options.Filters.ExactMatch("type", "container") {
+				go func() {
+					msgs <- events.Message{
+						Action: "health_status: unhealthy",
+						Actor:  events.Actor{ID: "1", Attributes: map[string]string{}},
+					}
+				}()
+			}
+			return msgs, errs
+		},
+		containerInspect: func(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+			return inspectedContainer(true), nil
+		},
+	}
+
+	docker := NewDockerWithClient(cli, "lacuna")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, errs := docker.Run(ctx)
+
+	select {
+	case event := <-events:
+		if event.Type != EVENT_TYPE_UNHEALTHY {
+			t.Errorf("expected event type to be '%s', got '%s'", EVENT_TYPE_UNHEALTHY, event.Type)
+		}
+		if !event.Container.HasHealthcheck() {
+			t.Errorf("expected container to be inspected, got %v", event.Container)
+		}
+	case err := <-errs:
+		t.Errorf("Run() returned error: %v", err)
+	}
+}
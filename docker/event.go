@@ -5,9 +5,17 @@ type EventType string
 const (
 	EVENT_TYPE_START EventType = "start"
 	EVENT_TYPE_STOP  EventType = "stop"
+	// The container was connected to or disconnected from a network
+	EVENT_TYPE_NETWORK EventType = "network"
+	// The healthcheck of the container reported a changed status
+	EVENT_TYPE_HEALTHY   EventType = "healthy"
+	EVENT_TYPE_UNHEALTHY EventType = "unhealthy"
+	// The container was paused or unpaused, e.g. using 'docker pause'
+	EVENT_TYPE_PAUSE   EventType = "pause"
+	EVENT_TYPE_UNPAUSE EventType = "unpause"
 )
 
 type Event struct {
-	Type      EventType // start or stop
-	Container Container // container that was started or stopped
+	Type      EventType // start, stop, network, healthy, unhealthy, pause or unpause
+	Container Container // container the event occurred on
 }
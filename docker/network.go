@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Network is a network a container is connected to.
+type Network struct {
+	Name    string
+	IP      string
+	Aliases []string
+}
+
+// Port is a port exposed by a container, along with
+// the host ports it is published on, if any.
+type Port struct {
+	Port      int
+	Protocol  string
+	HostPorts []int
+}
+
+// NetworkAddress returns the address the container is reachable at from other
+// containers in the network, i.e. '<host>' or '<host>:<port>'. If no port is
+// given, the only port exposed by the container is used, if any.
+func (container *Container) NetworkAddress(network string, port int) (string, error) {
+	host, err := container.NetworkHost(network)
+
+	if err != nil {
+		return "", err
+	}
+
+	// the only exposed port is used if none was given
+	if port == 0 {
+		if ports := container.tcpPorts(); len(ports) == 1 {
+			port = ports[0].Port
+		}
+	}
+
+	if port == 0 {
+		return host, nil
+	}
+
+	return host + ":" + strconv.Itoa(port), nil
+}
+
+// NetworkHost returns the host the container is reachable at from other
+// containers in the network. Compose networks may be given without their
+// project prefix. If no network is given, the first network of the container
+// is used. The host is the compose service name or another alias of the
+// container in the network, falling back to its IP.
+func (container *Container) NetworkHost(network string) (string, error) {
+	n, ok := container.network(network)
+
+	if !ok && network == "" {
+		return "", fmt.Errorf("container %s is not connected to any network", container.Name())
+	} else if !ok {
+		return "", fmt.Errorf("container %s is not connected to network %s", container.Name(), network)
+	}
+
+	host := n.IP
+
+	if len(n.Aliases) > 0 {
+		host = n.Aliases[0]
+	}
+
+	for _, alias := range n.Aliases {
+		if alias == container.Labels["com.docker.compose.service"] {
+			host = alias
+		}
+	}
+
+	if host == "" {
+		return "", fmt.Errorf("container %s has no address in network %s", container.Name(), n.Name)
+	}
+
+	return host, nil
+}
+
+// PublishedPort returns the host port the container port is published on. If
+// no port is given, the only published port of the container is used.
+func (container *Container) PublishedPort(port int) (int, error) {
+	published := make([]Port, 0)
+
+	for _, p := range container.tcpPorts() {
+		if len(p.HostPorts) > 0 {
+			published = append(published, p)
+		}
+	}
+
+	if port == 0 {
+		if len(published) != 1 {
+			return 0, fmt.Errorf("container %s publishes %d ports, a port must be given", container.Name(), len(published))
+		}
+
+		return published[0].HostPorts[0], nil
+	}
+
+	for _, p := range published {
+		if p.Port == port {
+			return p.HostPorts[0], nil
+		}
+	}
+
+	return 0, fmt.Errorf("port %d of container %s is not published", port, container.Name())
+}
+
+// network returns the network with the given name, or the
+// first network by name if no name is given.
+func (container *Container) network(name string) (Network, bool) {
+	if name == "" {
+		names := make([]string, 0, len(container.Networks))
+
+		for name := range container.Networks {
+			names = append(names, name)
+		}
+
+		if len(names) == 0 {
+			return Network{}, false
+		}
+
+		sort.Strings(names)
+
+		return container.Networks[names[0]], true
+	}
+
+	if n, ok := container.Networks[name]; ok {
+		return n, true
+	}
+
+	// compose prefixes networks with the project name
+	if project := container.ComposeProject(); project != "" {
+		n, ok := container.Networks[project+"_"+name]
+		return n, ok
+	}
+
+	return Network{}, false
+}
+
+func (container *Container) tcpPorts() []Port {
+	ports := make([]Port, 0, len(container.Ports))
+
+	for _, port := range container.Ports {
+		if port.Protocol == "tcp" {
+			ports = append(ports, port)
+		}
+	}
+
+	return ports
+}
@@ -1,16 +1,95 @@
 package docker
 
 import (
-	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
 )
 
-func mapEventType(action string) EventType {
+// mapEventType maps the action of a container event, and reports whether
+// the action is handled, e.g. 'health_status: starting' is not.
+func mapEventType(action string) (EventType, bool) {
 	switch action {
 	case startEventName:
-		return EVENT_TYPE_START
+		return EVENT_TYPE_START, true
 	case stopEventName:
-		return EVENT_TYPE_STOP
+		return EVENT_TYPE_STOP, true
+	case pauseEventName:
+		return EVENT_TYPE_PAUSE, true
+	case unpauseEventName:
+		return EVENT_TYPE_UNPAUSE, true
+	case healthStatusEventName + ": " + string(HEALTH_STATUS_HEALTHY):
+		return EVENT_TYPE_HEALTHY, true
+	case healthStatusEventName + ": " + string(HEALTH_STATUS_UNHEALTHY):
+		return EVENT_TYPE_UNHEALTHY, true
 	default:
-		panic(fmt.Sprintf("unsupported event type: %s", action))
+		return "", false
+	}
+}
+
+// mapContainer maps the result of inspecting a container,
+// including the networks it is connected to and its ports.
+func mapContainer(info types.ContainerJSON) Container {
+	container := NewContainer(info.ID, nil)
+
+	if info.Config != nil {
+		container.Labels = info.Config.Labels
+		container.Env = make(map[string]string, len(info.Config.Env))
+
+		for _, variable := range info.Config.Env {
+			key, value, _ := strings.Cut(variable, "=")
+			container.Env[key] = value
+		}
 	}
+
+	if info.ContainerJSONBase != nil && info.State != nil {
+		container.Paused = info.State.Paused
+
+		if info.State.Health != nil {
+			container.Health = HealthStatus(info.State.Health.Status)
+		}
+	}
+
+	if info.NetworkSettings == nil {
+		return container
+	}
+
+	container.Networks = make(map[string]Network, len(info.NetworkSettings.Networks))
+
+	for name, settings := range info.NetworkSettings.Networks {
+		if settings == nil {
+			continue
+		}
+
+		container.Networks[name] = Network{
+			Name:    name,
+			IP:      settings.IPAddress,
+			Aliases: settings.Aliases,
+		}
+	}
+
+	for port, bindings := range info.NetworkSettings.Ports {
+		p := Port{Port: port.Int(), Protocol: port.Proto()}
+
+		for _, binding := range bindings {
+			hostPort, err := strconv.Atoi(binding.HostPort)
+
+			// ports published on both ipv4 and ipv6 are bound twice
+			if err != nil || (len(p.HostPorts) > 0 && p.HostPorts[len(p.HostPorts)-1] == hostPort) {
+				continue
+			}
+
+			p.HostPorts = append(p.HostPorts, hostPort)
+		}
+
+		container.Ports = append(container.Ports, p)
+	}
+
+	sort.Slice(container.Ports, func(i, j int) bool {
+		return container.Ports[i].Port < container.Ports[j].Port
+	})
+
+	return container
 }
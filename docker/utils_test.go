@@ -6,13 +6,19 @@ import (
 
 func TestMapValidEventTypeSucceeds(t *testing.T) {
 	// arrange
-	actions := []string{startEventName, stopEventName}
-	eventTypes := []EventType{EVENT_TYPE_START, EVENT_TYPE_STOP}
+	actions := []string{startEventName, stopEventName, pauseEventName, unpauseEventName, "health_status: healthy", "health_status: unhealthy"}
+	eventTypes := []EventType{EVENT_TYPE_START, EVENT_TYPE_STOP, EVENT_TYPE_PAUSE, EVENT_TYPE_UNPAUSE, EVENT_TYPE_HEALTHY, EVENT_TYPE_UNHEALTHY}
 	extractedEventTypes := make([]EventType, 0)
 
 	// act
 	for _, action := range actions {
-		extractedEventTypes = append(extractedEventTypes, mapEventType(action))
+		eventType, ok := mapEventType(action)
+
+		if !ok {
+			t.Errorf("expected action '%s' to be supported", action)
+		}
+
+		extractedEventTypes = append(extractedEventTypes, eventType)
 	}
 
 	// assert
@@ -25,13 +31,15 @@ func TestMapValidEventTypeSucceeds(t *testing.T) {
 
 func TestMapInvalidEventTypeFails(t *testing.T) {
 	// arrange
-	action := "foobar"
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("expected extractEventType to panic")
-		}
-	}()
+	actions := []string{"foobar", "health_status: starting"}
 
-	// act
-	mapEventType(action)
+	for _, action := range actions {
+		// act
+		_, ok := mapEventType(action)
+
+		// assert
+		if ok {
+			t.Errorf("expected action '%s' not to be supported", action)
+		}
+	}
 }
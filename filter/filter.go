@@ -0,0 +1,331 @@
+// Package filter implements the pub/sub subscription filter language,
+// see https://cloud.google.com/pubsub/docs/subscription-message-filter.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Maximum length of a filter in bytes, as enforced by pub/sub
+const MAX_FILTER_LENGTH = 256
+
+// Filter is a parsed filter expression, which matches message attributes.
+type Filter interface {
+	Match(attributes map[string]string) bool
+}
+
+type andFilter []Filter
+
+func (f andFilter) Match(attributes map[string]string) bool {
+	for _, filter := range f {
+		if !filter.Match(attributes) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type orFilter []Filter
+
+func (f orFilter) Match(attributes map[string]string) bool {
+	for _, filter := range f {
+		if filter.Match(attributes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+func (f notFilter) Match(attributes map[string]string) bool {
+	return !f.filter.Match(attributes)
+}
+
+// hasFilter matches 'attributes:key'
+type hasFilter struct {
+	key string
+}
+
+func (f hasFilter) Match(attributes map[string]string) bool {
+	_, ok := attributes[f.key]
+
+	return ok
+}
+
+// equalFilter matches 'attributes.key = "value"', and its negation using '!='
+type equalFilter struct {
+	key   string
+	value string
+	not   bool
+}
+
+func (f equalFilter) Match(attributes map[string]string) bool {
+	value, ok := attributes[f.key]
+
+	if f.not {
+		return !ok || value != f.value
+	}
+
+	return ok && value == f.value
+}
+
+// prefixFilter matches 'hasPrefix(attributes.key, "prefix")'
+type prefixFilter struct {
+	key    string
+	prefix string
+}
+
+func (f prefixFilter) Match(attributes map[string]string) bool {
+	value, ok := attributes[f.key]
+
+	return ok && strings.HasPrefix(value, f.prefix)
+}
+
+// Parse parses a filter, and returns an error describing the
+// position and cause if the filter is invalid.
+func Parse(filter string) (Filter, error) {
+	if len(filter) > MAX_FILTER_LENGTH {
+		return nil, fmt.Errorf("filter must not be longer than %d bytes", MAX_FILTER_LENGTH)
+	}
+
+	tokens, err := tokenize(filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	f, err := p.parseExpression()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.typ != TOKEN_EOF {
+		return nil, p.unexpected(next, "AND, OR or end of filter")
+	}
+
+	return f, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+
+	if t.typ != TOKEN_EOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expect(typ tokenType, expected string) (token, error) {
+	t := p.next()
+
+	if t.typ != typ {
+		return t, p.unexpected(t, expected)
+	}
+
+	return t, nil
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	return fmt.Errorf("unexpected %s at position %d, expected %s", t, t.pos+1, expected)
+}
+
+// parseExpression parses terms joined by either AND or OR. As in pub/sub,
+// mixing both operators requires parentheses.
+func (p *parser) parseExpression() (Filter, error) {
+	first, err := p.parseTerm()
+
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []Filter{first}
+	operator := TOKEN_EOF
+
+	for {
+		t := p.peek()
+
+		if t.typ != TOKEN_AND && t.typ != TOKEN_OR {
+			break
+		}
+
+		if operator != TOKEN_EOF && operator != t.typ {
+			return nil, fmt.Errorf("unexpected %s at position %d, AND and OR must not be mixed without parentheses", t, t.pos+1)
+		}
+
+		operator = p.next().typ
+
+		term, err := p.parseTerm()
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, term)
+	}
+
+	switch operator {
+	case TOKEN_AND:
+		return andFilter(filters), nil
+	case TOKEN_OR:
+		return orFilter(filters), nil
+	default:
+		return first, nil
+	}
+}
+
+// parseTerm parses a negated or plain condition, or a parenthesized expression.
+func (p *parser) parseTerm() (Filter, error) {
+	switch t := p.peek(); t.typ {
+	case TOKEN_NOT, TOKEN_MINUS:
+		p.next()
+
+		term, err := p.parseTerm()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return notFilter{term}, nil
+	case TOKEN_LPAREN:
+		p.next()
+
+		expression, err := p.parseExpression()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(TOKEN_RPAREN, "')'"); err != nil {
+			return nil, err
+		}
+
+		return expression, nil
+	case TOKEN_IDENT:
+		if t.value == "hasPrefix" {
+			return p.parsePrefix()
+		}
+
+		return p.parseCondition()
+	default:
+		return nil, p.unexpected(t, "a condition")
+	}
+}
+
+// parsePrefix parses 'hasPrefix(attributes.key, "prefix")'.
+func (p *parser) parsePrefix() (Filter, error) {
+	p.next()
+
+	if _, err := p.expect(TOKEN_LPAREN, "'('"); err != nil {
+		return nil, err
+	}
+
+	if err := p.parseAttributes(); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(TOKEN_DOT, "'.'"); err != nil {
+		return nil, err
+	}
+
+	key, err := p.parseKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(TOKEN_COMMA, "','"); err != nil {
+		return nil, err
+	}
+
+	prefix, err := p.expect(TOKEN_STRING, "a quoted prefix")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(TOKEN_RPAREN, "')'"); err != nil {
+		return nil, err
+	}
+
+	return prefixFilter{key: key, prefix: prefix.value}, nil
+}
+
+// parseCondition parses 'attributes:key', 'attributes.key = "value"'
+// and 'attributes.key != "value"'.
+func (p *parser) parseCondition() (Filter, error) {
+	if err := p.parseAttributes(); err != nil {
+		return nil, err
+	}
+
+	separator := p.next()
+
+	if separator.typ != TOKEN_COLON && separator.typ != TOKEN_DOT {
+		return nil, p.unexpected(separator, "'.' or ':'")
+	}
+
+	key, err := p.parseKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if separator.typ == TOKEN_COLON {
+		return hasFilter{key: key}, nil
+	}
+
+	operator := p.next()
+
+	if operator.typ != TOKEN_EQ && operator.typ != TOKEN_NEQ {
+		return nil, p.unexpected(operator, "'=' or '!='")
+	}
+
+	value, err := p.expect(TOKEN_STRING, "a quoted value")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return equalFilter{key: key, value: value.value, not: operator.typ == TOKEN_NEQ}, nil
+}
+
+// parseAttributes parses the 'attributes' keyword, the only
+// message field filters may refer to.
+func (p *parser) parseAttributes() error {
+	t := p.next()
+
+	if t.typ != TOKEN_IDENT || t.value != "attributes" {
+		return p.unexpected(t, "'attributes'")
+	}
+
+	return nil
+}
+
+// parseKey parses an attribute key, which is either an identifier or a quoted string.
+func (p *parser) parseKey() (string, error) {
+	t := p.next()
+
+	if t.typ != TOKEN_IDENT && t.typ != TOKEN_STRING {
+		return "", p.unexpected(t, "an attribute key")
+	}
+
+	return t.value, nil
+}
@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMatchesAttributes(t *testing.T) {
+	attributes := map[string]string{
+		"type":     "order",
+		"region":   "europe-west1",
+		"my key":   "value",
+		"priority": "",
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`attributes:type`, true},
+		{`attributes:missing`, false},
+		{`attributes.type = "order"`, true},
+		{`attributes.type = "payment"`, false},
+		{`attributes.type != "payment"`, true},
+		{`attributes.missing != "payment"`, true},
+		{`attributes."my key" = "value"`, true},
+		{`hasPrefix(attributes.region, "europe-")`, true},
+		{`hasPrefix(attributes.region, "us-")`, false},
+		{`NOT attributes:missing`, true},
+		{`-attributes:type`, false},
+		{`attributes:type AND attributes.region = "europe-west1"`, true},
+		{`attributes:type AND attributes:missing`, false},
+		{`attributes:missing OR attributes.type = "order"`, true},
+		{`attributes:missing OR NOT attributes:type`, false},
+		{`(attributes:missing OR attributes:type) AND attributes:priority`, true},
+		{`NOT (attributes:type AND attributes:missing)`, true},
+	}
+
+	for _, test := range tests {
+		// act
+		filter, err := Parse(test.filter)
+
+		// assert
+		if err != nil {
+			t.Errorf("expected filter '%s' to be valid, got %v", test.filter, err)
+			continue
+		}
+
+		if match := filter.Match(attributes); match != test.match {
+			t.Errorf("expected filter '%s' to match %v, got %v", test.filter, test.match, match)
+		}
+	}
+}
+
+func TestParseRejectsInvalidFilters(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    string
+	}{
+		{``, "unexpected end of filter at position 1"},
+		{`attributes.type = order`, "expected a quoted value"},
+		{`attributes.type == "order"`, "unexpected '=' at position 18"},
+		{`attribute.type = "order"`, "expected 'attributes'"},
+		{`attributes:type AND attributes:region OR attributes:key`, "AND and OR must not be mixed"},
+		{`(attributes:type`, "expected ')'"},
+		{`hasPrefix(attributes.type "order")`, "expected ','"},
+		{`attributes.type = "order`, "unterminated string"},
+		{`attributes:type and attributes:region`, "expected AND, OR or end of filter"},
+		{`attributes:type ~`, "unexpected character '~'"},
+		{`attributes:` + strings.Repeat("a", 256), "must not be longer than 256 bytes"},
+	}
+
+	for _, test := range tests {
+		// act
+		_, err := Parse(test.filter)
+
+		// assert
+		if err == nil {
+			t.Errorf("expected filter '%s' to be invalid", test.filter)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected error of filter '%s' to contain '%s', got '%v'", test.filter, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	TOKEN_EOF tokenType = iota
+	TOKEN_IDENT
+	TOKEN_STRING
+	TOKEN_LPAREN
+	TOKEN_RPAREN
+	TOKEN_DOT
+	TOKEN_COLON
+	TOKEN_COMMA
+	TOKEN_EQ
+	TOKEN_NEQ
+	TOKEN_MINUS
+	TOKEN_AND
+	TOKEN_OR
+	TOKEN_NOT
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case TOKEN_EOF:
+		return "end of filter"
+	case TOKEN_STRING:
+		return fmt.Sprintf("%q", t.value)
+	default:
+		return fmt.Sprintf("'%s'", t.value)
+	}
+}
+
+// tokenize splits a filter into its tokens. Keywords are only
+// recognized in upper case, as in the pub/sub filter syntax.
+func tokenize(input string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{TOKEN_LPAREN, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{TOKEN_RPAREN, ")", i})
+			i++
+		case r == '.':
+			tokens = append(tokens, token{TOKEN_DOT, ".", i})
+			i++
+		case r == ':':
+			tokens = append(tokens, token{TOKEN_COLON, ":", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{TOKEN_COMMA, ",", i})
+			i++
+		case r == '=':
+			tokens = append(tokens, token{TOKEN_EQ, "=", i})
+			i++
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{TOKEN_NEQ, "!=", i})
+			i += 2
+		case r == '-':
+			tokens = append(tokens, token{TOKEN_MINUS, "-", i})
+			i++
+		case r == '"':
+			value, end, err := readString(runes, i)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{TOKEN_STRING, value, i})
+			i = end
+		case isIdentRune(r):
+			start := i
+
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+
+			value := string(runes[start:i])
+
+			switch value {
+			case "AND":
+				tokens = append(tokens, token{TOKEN_AND, value, start})
+			case "OR":
+				tokens = append(tokens, token{TOKEN_OR, value, start})
+			case "NOT":
+				tokens = append(tokens, token{TOKEN_NOT, value, start})
+			default:
+				tokens = append(tokens, token{TOKEN_IDENT, value, start})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", r, i+1)
+		}
+	}
+
+	return append(tokens, token{TOKEN_EOF, "", len(runes)}), nil
+}
+
+// readString reads a double quoted string starting at the given position, and
+// returns its unescaped value along with the position following the string.
+func readString(runes []rune, start int) (string, int, error) {
+	var value strings.Builder
+
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '"':
+			return value.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(runes) {
+				break
+			}
+
+			i++
+			value.WriteRune(runes[i])
+		default:
+			value.WriteRune(runes[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string starting at position %d", start+1)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
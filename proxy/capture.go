@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+)
+
+// captures stores the messages received by capture sinks, by route path,
+// as subscriptions in different projects may share their ID.
+type captures struct {
+	mu       sync.Mutex
+	messages map[string][]pubsub.Message
+	// closed and replaced whenever a message is captured by the sink
+	changed map[string]chan struct{}
+}
+
+func newCaptures() *captures {
+	return &captures{
+		messages: make(map[string][]pubsub.Message),
+		changed:  make(map[string]chan struct{}),
+	}
+}
+
+func (c *captures) add(sink string, message pubsub.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages[sink] = append(c.messages[sink], message)
+
+	if changed, ok := c.changed[sink]; ok {
+		close(changed)
+		delete(c.changed, sink)
+	}
+}
+
+func (c *captures) list(sink string) []pubsub.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append(make([]pubsub.Message, 0, len(c.messages[sink])), c.messages[sink]...)
+}
+
+func (c *captures) clear(sink string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.messages, sink)
+}
+
+// wait blocks until the sink captured at least count messages, and returns
+// them. If the context is done before, the messages captured so far are
+// returned along with the context's error.
+func (c *captures) wait(ctx context.Context, sink string, count int) ([]pubsub.Message, error) {
+	for {
+		c.mu.Lock()
+
+		if len(c.messages[sink]) >= count {
+			messages := append(make([]pubsub.Message, 0, len(c.messages[sink])), c.messages[sink]...)
+			c.mu.Unlock()
+			return messages, nil
+		}
+
+		changed, ok := c.changed[sink]
+
+		if !ok {
+			changed = make(chan struct{})
+			c.changed[sink] = changed
+		}
+
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return c.list(sink), ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// capture stores a message pushed to a capture sink and acknowledges it.
+func (p *proxyImpl) capture(w http.ResponseWriter, route Route, message pubsub.Message) {
+	p.captures.add(route.path(), message)
+
+	p.log.WithField("container", route.Container).WithField("subscription", route.Subscription).WithField("message_id", message.ID).Debug("message captured")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveCaptures lists the messages captured by a sink on GET, optionally
+// waiting for a number of messages given by 'count' up to 'timeout', and
+// clears them on DELETE.
+func (p *proxyImpl) serveCaptures(w http.ResponseWriter, r *http.Request) {
+	sink := strings.TrimPrefix(r.URL.Path, "/captures")
+
+	// sinks may be queried before they are registered, or after they were
+	// unregistered, so their path is validated instead of looked up
+	if parts := strings.Split(sink, "/"); len(parts) != 5 || parts[2] == "" || parts[3] != "subscriptions" || parts[4] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		p.captures.clear(sink)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	status := http.StatusOK
+	messages := p.captures.list(sink)
+
+	if query.Has("count") {
+		count, err := strconv.Atoi(query.Get("count"))
+
+		if err != nil || count < 0 {
+			http.Error(w, "invalid count, must be a positive integer", http.StatusBadRequest)
+			return
+		}
+
+		timeout := 30 * time.Second
+
+		if query.Has("timeout") {
+			if timeout, err = time.ParseDuration(query.Get("timeout")); err != nil {
+				http.Error(w, "invalid timeout, must be a valid duration", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if messages, err = p.captures.wait(ctx, sink, count); err != nil {
+			status = http.StatusRequestTimeout
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(messages)
+}
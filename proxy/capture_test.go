@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+)
+
+func captureTestMessage(proxy *proxyImpl) int {
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/tests_orders", strings.NewReader(testEnvelope))
+	res := httptest.NewRecorder()
+
+	proxy.ServeHTTP(res, req)
+
+	return res.Code
+}
+
+func getCaptures(t *testing.T, proxy *proxyImpl, query string) (int, []pubsub.Message) {
+	req := httptest.NewRequest(http.MethodGet, "/captures/projects/test/subscriptions/tests_orders"+query, nil)
+	res := httptest.NewRecorder()
+
+	proxy.handler().ServeHTTP(res, req)
+
+	var messages []pubsub.Message
+
+	if err := json.NewDecoder(res.Body).Decode(&messages); err != nil {
+		t.Fatalf("expected captured messages, got %v", err)
+	}
+
+	return res.Code, messages
+}
+
+func TestServeHTTPCapturesMessagesOfSinks(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "tests_orders", Capture: true})
+
+	// act
+	code := captureTestMessage(proxy)
+
+	// assert
+	if code != http.StatusNoContent {
+		t.Errorf("expected captured message to be acknowledged, got %d", code)
+	}
+
+	status, messages := getCaptures(t, proxy, "")
+
+	if status != http.StatusOK {
+		t.Errorf("expected status to be %d, got %d", http.StatusOK, status)
+	}
+
+	if len(messages) != 1 || messages[0].ID != "42" || string(messages[0].Data) != "hello" {
+		t.Errorf("expected captured message, got %+v", messages)
+	}
+}
+
+func TestServeCapturesWaitsForMessages(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "tests_orders", Capture: true})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		captureTestMessage(proxy)
+		captureTestMessage(proxy)
+	}()
+
+	// act
+	status, messages := getCaptures(t, proxy, "?count=2&timeout=5s")
+
+	// assert
+	if status != http.StatusOK {
+		t.Errorf("expected status to be %d, got %d", http.StatusOK, status)
+	}
+
+	if len(messages) != 2 {
+		t.Errorf("expected 2 captured messages, got %d", len(messages))
+	}
+}
+
+func TestServeCapturesTimesOutWaitingForMessages(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "tests_orders", Capture: true})
+	captureTestMessage(proxy)
+
+	// act
+	status, messages := getCaptures(t, proxy, "?count=2&timeout=10ms")
+
+	// assert
+	if status != http.StatusRequestTimeout {
+		t.Errorf("expected status to be %d, got %d", http.StatusRequestTimeout, status)
+	}
+
+	if len(messages) != 1 {
+		t.Errorf("expected messages captured so far, got %d", len(messages))
+	}
+}
+
+func TestServeCapturesClearsMessages(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "tests_orders", Capture: true})
+	captureTestMessage(proxy)
+
+	req := httptest.NewRequest(http.MethodDelete, "/captures/projects/test/subscriptions/tests_orders", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.handler().ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusNoContent {
+		t.Errorf("expected status to be %d, got %d", http.StatusNoContent, res.Code)
+	}
+
+	if _, messages := getCaptures(t, proxy, ""); len(messages) != 0 {
+		t.Errorf("expected captured messages to be cleared, got %d", len(messages))
+	}
+}
+
+func TestServeCapturesSeparatesProjects(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "tests_orders", Capture: true})
+	proxy.Register(Route{Project: "other", Subscription: "tests_orders", Capture: true})
+	captureTestMessage(proxy)
+
+	req := httptest.NewRequest(http.MethodGet, "/captures/projects/other/subscriptions/tests_orders", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.handler().ServeHTTP(res, req)
+
+	// assert
+	var messages []pubsub.Message
+
+	if err := json.NewDecoder(res.Body).Decode(&messages); err != nil {
+		t.Fatalf("expected captured messages, got %v", err)
+	}
+
+	if len(messages) != 0 {
+		t.Errorf("expected messages of other projects not to be listed, got %d", len(messages))
+	}
+}
+
+func TestServeCapturesRejectsInvalidSink(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+
+	req := httptest.NewRequest(http.MethodGet, "/captures/projects/test/tests_orders", nil)
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.handler().ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusNotFound {
+		t.Errorf("expected status to be %d, got %d", http.StatusNotFound, res.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Chaos describes the faults injected when relaying push requests.
+type Chaos struct {
+	// Share of requests answered with an error without relaying them
+	ErrorRate float64
+	// Delay before relaying requests
+	Latency time.Duration
+	// Share of requests relayed twice
+	DuplicateRate float64
+	// Share of requests whose response is dropped after relaying them
+	DropRate float64
+}
+
+// jsonChaos is the JSON representation of chaos options, using
+// duration strings for the latency.
+type jsonChaos struct {
+	ErrorRate     float64 `json:"error_rate"`
+	Latency       string  `json:"latency"`
+	DuplicateRate float64 `json:"duplicate_rate"`
+	DropRate      float64 `json:"drop_rate"`
+}
+
+// Enabled reports whether any fault is injected.
+func (c Chaos) Enabled() bool {
+	return c != Chaos{}
+}
+
+// Validate checks that rates are within 0 and 1, and the latency is positive.
+func (c Chaos) Validate() error {
+	for name, rate := range map[string]float64{"error-rate": c.ErrorRate, "duplicate-rate": c.DuplicateRate, "drop-rate": c.DropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid %s: %v, must be between 0 and 1", name, rate)
+		}
+	}
+
+	if c.Latency < 0 {
+		return fmt.Errorf("invalid latency: %s, must not be negative", c.Latency)
+	}
+
+	return nil
+}
+
+func (c Chaos) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonChaos{
+		ErrorRate:     c.ErrorRate,
+		Latency:       c.Latency.String(),
+		DuplicateRate: c.DuplicateRate,
+		DropRate:      c.DropRate,
+	})
+}
+
+func (c *Chaos) UnmarshalJSON(b []byte) error {
+	var chaos jsonChaos
+
+	if err := json.Unmarshal(b, &chaos); err != nil {
+		return err
+	}
+
+	*c = Chaos{
+		ErrorRate:     chaos.ErrorRate,
+		DuplicateRate: chaos.DuplicateRate,
+		DropRate:      chaos.DropRate,
+	}
+
+	if chaos.Latency != "" {
+		latency, err := time.ParseDuration(chaos.Latency)
+
+		if err != nil {
+			return err
+		}
+
+		c.Latency = latency
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newChaosTestServer(calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func serveTestPush(proxy *proxyImpl) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader("message"))
+	res := httptest.NewRecorder()
+
+	proxy.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestServeHTTPInjectsErrorsWithoutRelaying(t *testing.T) {
+	// arrange
+	calls := 0
+	target := newChaosTestServer(&calls)
+	defer target.Close()
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL, Chaos: Chaos{ErrorRate: 1}})
+
+	// act
+	res := serveTestPush(proxy)
+
+	// assert
+	if res.Code != http.StatusInternalServerError {
+		t.Errorf("expected status to be %d, got %d", http.StatusInternalServerError, res.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("expected request not to be relayed, got %d calls", calls)
+	}
+}
+
+func TestServeHTTPDuplicatesRequests(t *testing.T) {
+	// arrange
+	calls := 0
+	target := newChaosTestServer(&calls)
+	defer target.Close()
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL, Chaos: Chaos{DuplicateRate: 1}})
+
+	// act
+	res := serveTestPush(proxy)
+
+	// assert
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status to be %d, got %d", http.StatusOK, res.Code)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected request to be relayed twice, got %d calls", calls)
+	}
+}
+
+func TestServeHTTPDropsResponsesAfterRelaying(t *testing.T) {
+	// arrange
+	calls := 0
+	target := newChaosTestServer(&calls)
+	defer target.Close()
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL, Chaos: Chaos{DropRate: 1}})
+
+	// act
+	res := serveTestPush(proxy)
+
+	// assert
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status to be %d, got %d", http.StatusServiceUnavailable, res.Code)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected request to be relayed once, got %d calls", calls)
+	}
+}
+
+func TestServeChaosUpdatesChaosOfRoute(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders"})
+
+	req := httptest.NewRequest(http.MethodPut, "/chaos/projects/test/subscriptions/service_orders", strings.NewReader(`{"error_rate":0.5,"latency":"100ms"}`))
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.handler().ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status to be %d, got %d", http.StatusOK, res.Code)
+	}
+
+	route := proxy.routes["/projects/test/subscriptions/service_orders"]
+
+	if route.Chaos.ErrorRate != 0.5 || route.Chaos.Latency != 100*time.Millisecond {
+		t.Errorf("expected chaos to be updated, got %+v", route.Chaos)
+	}
+}
+
+func TestRegisterKeepsChaosChangedAtRuntime(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	route := Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders", Chaos: Chaos{Latency: time.Second}}
+	proxy.Register(route)
+
+	if err := proxy.SetChaos("test", "service_orders", Chaos{ErrorRate: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	proxy.Register(route)
+
+	// assert
+	if chaos := proxy.routes[route.path()].Chaos; chaos.ErrorRate != 0.5 || chaos.Latency != 0 {
+		t.Errorf("expected chaos changed at runtime to be kept, got %+v", chaos)
+	}
+}
+
+func TestRegisterResetsChaosOfUnregisteredRoute(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	route := Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders", Chaos: Chaos{Latency: time.Second}}
+	proxy.Register(route)
+
+	if err := proxy.SetChaos("test", "service_orders", Chaos{ErrorRate: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy.Unregister(route)
+
+	// act
+	proxy.Register(route)
+
+	// assert
+	if chaos := proxy.routes[route.path()].Chaos; chaos != route.Chaos {
+		t.Errorf("expected chaos to be reset to %+v, got %+v", route.Chaos, chaos)
+	}
+}
+
+func TestServeChaosRejectsInvalidRates(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders"})
+
+	req := httptest.NewRequest(http.MethodPut, "/chaos/projects/test/subscriptions/service_orders", strings.NewReader(`{"drop_rate":2}`))
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.handler().ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("expected status to be %d, got %d", http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestSetChaosFailsForUnknownRoute(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+
+	// act
+	err := proxy.SetChaos("test", "service_orders", Chaos{ErrorRate: 1})
+
+	// assert
+	if err != ErrUnknownRoute {
+		t.Errorf("expected err to be ErrUnknownRoute, got %v", err)
+	}
+}
+
+func TestChaosRoundTripsThroughJSON(t *testing.T) {
+	// arrange
+	chaos := Chaos{ErrorRate: 0.1, Latency: time.Second, DuplicateRate: 0.2, DropRate: 0.3}
+
+	// act
+	b, _ := json.Marshal(chaos)
+
+	var decoded Chaos
+	err := json.Unmarshal(b, &decoded)
+
+	// assert
+	if err != nil {
+		t.Errorf("expected err to be nil, got %v", err)
+	}
+
+	if decoded != chaos {
+		t.Errorf("expected chaos to be %+v, got %+v", chaos, decoded)
+	}
+}
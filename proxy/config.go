@@ -0,0 +1,26 @@
+package proxy
+
+import "github.com/spf13/viper"
+
+type Config struct {
+	// Relay all push subscriptions through the proxy, not only
+	// subscriptions which opted in using the proxy label
+	Enabled bool `mapstructure:"enabled"`
+	// Relay push subscriptions with a dead-letter or retry policy through
+	// the proxy, which enforces the policies the emulator ignores
+	Delivery bool `mapstructure:"delivery"`
+	// Address the proxy listens on
+	Address string `mapstructure:"address"`
+	// URL the emulator reaches the proxy at
+	URL string `mapstructure:"url"`
+	// Number of bytes of request and response bodies to log
+	BodyLimit int `mapstructure:"body_limit"`
+}
+
+func init() {
+	viper.SetDefault("proxy.enabled", false)
+	viper.SetDefault("proxy.delivery", false)
+	viper.SetDefault("proxy.address", ":8090")
+	viper.SetDefault("proxy.url", "http://lacuna:8090")
+	viper.SetDefault("proxy.body_limit", 1024)
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+)
+
+// Attributes added to dead-lettered messages, as done by pub/sub
+const (
+	ATTRIBUTE_DEAD_LETTER_DELIVERY_COUNT       = "CloudPubSubDeadLetterSourceDeliveryCount"
+	ATTRIBUTE_DEAD_LETTER_SUBSCRIPTION         = "CloudPubSubDeadLetterSourceSubscription"
+	ATTRIBUTE_DEAD_LETTER_SUBSCRIPTION_PROJECT = "CloudPubSubDeadLetterSourceSubscriptionProject"
+	DEFAULT_MAX_DELIVERY_ATTEMPTS              = 5
+	DEFAULT_MINIMUM_BACKOFF                    = 10 * time.Second
+	DEFAULT_MAXIMUM_BACKOFF                    = 600 * time.Second
+	// Deliveries of messages not attempted for this long are forgotten
+	DELIVERY_TTL = time.Hour
+)
+
+// DeliveryPolicy describes the retry and dead-letter policy of a subscription,
+// which the emulator does not enforce, so it is enforced by the proxy instead.
+type DeliveryPolicy struct {
+	// Fully qualified name of the dead-letter topic, if any
+	DeadLetterTopic     string
+	MaxDeliveryAttempts int
+	// Backoff between delivery attempts, disabled if both are zero
+	MinimumBackoff time.Duration
+	MaximumBackoff time.Duration
+}
+
+// backoff returns the exponential backoff after the given failed attempt.
+func (d *DeliveryPolicy) backoff(attempt int) time.Duration {
+	if d.MinimumBackoff == 0 && d.MaximumBackoff == 0 {
+		return 0
+	}
+
+	minimum, maximum := d.MinimumBackoff, d.MaximumBackoff
+
+	if minimum == 0 {
+		minimum = DEFAULT_MINIMUM_BACKOFF
+	}
+
+	if maximum == 0 {
+		maximum = DEFAULT_MAXIMUM_BACKOFF
+	}
+
+	backoff := minimum
+
+	for i := 1; i < attempt && backoff < maximum; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maximum {
+		backoff = maximum
+	}
+
+	return backoff
+}
+
+// maxDeliveryAttempts returns the number of attempts after which
+// messages are dead-lettered.
+func (d *DeliveryPolicy) maxDeliveryAttempts() int {
+	if d.MaxDeliveryAttempts == 0 {
+		return DEFAULT_MAX_DELIVERY_ATTEMPTS
+	}
+
+	return d.MaxDeliveryAttempts
+}
+
+// pushEnvelope is the body of wrapped push requests.
+type pushEnvelope struct {
+	Message struct {
+		Data        []byte            `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		MessageID   string            `json:"messageId"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"message"`
+}
+
+// parsePushMessage returns the message delivered by a push request, and whether
+// it was wrapped in an envelope. The body of unwrapped push requests is the message
+// data, which is identified by the message ID header if metadata is written, or by
+// the hash of the body otherwise.
+func parsePushMessage(r *http.Request, body []byte) (pubsub.Message, bool) {
+	var envelope pushEnvelope
+
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.Message.MessageID != "" {
+		return pubsub.Message{
+			ID:          envelope.Message.MessageID,
+			Data:        envelope.Message.Data,
+			Attributes:  envelope.Message.Attributes,
+			OrderingKey: envelope.Message.OrderingKey,
+		}, true
+	}
+
+	id := r.Header.Get("x-goog-pubsub-message-id")
+
+	if id == "" {
+		hash := sha256.Sum256(body)
+		id = hex.EncodeToString(hash[:])
+	}
+
+	return pubsub.Message{ID: id, Data: body}, false
+}
+
+// delivery tracks the delivery attempts of a message.
+type delivery struct {
+	attempts int
+	// redeliveries before this time are rejected
+	retryAt time.Time
+	// time of the last attempt
+	lastAttempt time.Time
+}
+
+// attempt records a delivery attempt of the message, and returns its number.
+// Redeliveries before the retry backoff of the previous attempt elapsed are
+// not recorded, in which case false is returned and the message must be rejected.
+func (p *proxyImpl) attempt(route Route, messageID string) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prune()
+
+	deliveries, ok := p.deliveries[route.path()]
+
+	if !ok {
+		deliveries = make(map[string]*delivery)
+		p.deliveries[route.path()] = deliveries
+	}
+
+	d, ok := deliveries[messageID]
+
+	if !ok {
+		d = &delivery{}
+		deliveries[messageID] = d
+	}
+
+	if p.now().Before(d.retryAt) {
+		return d.attempts, false
+	}
+
+	d.attempts++
+	d.lastAttempt = p.now()
+
+	return d.attempts, true
+}
+
+// prune forgets deliveries of messages which were not attempted within the
+// delivery TTL, as messages may never be redelivered, e.g. once they expired.
+// It must be called with the lock held.
+func (p *proxyImpl) prune() {
+	now := p.now()
+
+	if now.Sub(p.pruned) < time.Minute {
+		return
+	}
+
+	p.pruned = now
+
+	for path, deliveries := range p.deliveries {
+		for messageID, d := range deliveries {
+			if now.Sub(d.lastAttempt) > DELIVERY_TTL {
+				delete(deliveries, messageID)
+			}
+		}
+
+		if len(deliveries) == 0 {
+			delete(p.deliveries, path)
+		}
+	}
+}
+
+// retry rejects redeliveries of the message until the backoff elapsed.
+func (p *proxyImpl) retry(route Route, messageID string, backoff time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if d, ok := p.deliveries[route.path()][messageID]; ok {
+		d.retryAt = p.now().Add(backoff)
+	}
+}
+
+// delivered forgets the attempts of a message once it was acknowledged.
+func (p *proxyImpl) delivered(route Route, messageID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.deliveries[route.path()], messageID)
+}
+
+// failed handles a failed delivery attempt according to the delivery policy of
+// the route. Messages which reached the max delivery attempts are published to
+// the dead-letter topic, in which case true is returned and the message must be
+// acknowledged. Otherwise, redeliveries are rejected until the retry backoff elapsed.
+func (p *proxyImpl) failed(r *http.Request, log *log.Entry, route Route, message pubsub.Message, attempt int) bool {
+	if route.Delivery == nil {
+		return false
+	}
+
+	if route.Delivery.DeadLetterTopic != "" && attempt >= route.Delivery.maxDeliveryAttempts() {
+		if err := p.deadLetter(r.Context(), route, message, attempt); err != nil {
+			log.WithError(err).Error("error publishing message to dead-letter topic")
+		} else {
+			log.WithField("dead_letter_topic", route.Delivery.DeadLetterTopic).Info("message dead-lettered")
+			p.delivered(route, message.ID)
+			return true
+		}
+	}
+
+	if backoff := route.Delivery.backoff(attempt); backoff > 0 {
+		log.WithField("backoff", backoff).Debug("delaying redelivery")
+		p.retry(route, message.ID, backoff)
+	}
+
+	return false
+}
+
+// deadLetter publishes the message to the dead-letter topic of the route.
+func (p *proxyImpl) deadLetter(ctx context.Context, route Route, message pubsub.Message, attempt int) error {
+	attributes := make(map[string]string)
+
+	for key, value := range message.Attributes {
+		attributes[key] = value
+	}
+
+	attributes[ATTRIBUTE_DEAD_LETTER_DELIVERY_COUNT] = strconv.Itoa(attempt)
+	attributes[ATTRIBUTE_DEAD_LETTER_SUBSCRIPTION] = route.Subscription
+	attributes[ATTRIBUTE_DEAD_LETTER_SUBSCRIPTION_PROJECT] = route.Project
+
+	_, err := p.pubsub.Publish(ctx, pubsub.Topic{Name: route.Delivery.DeadLetterTopic}, []pubsub.Message{{
+		Data:        message.Data,
+		Attributes:  attributes,
+		OrderingKey: message.OrderingKey,
+	}})
+
+	return err
+}
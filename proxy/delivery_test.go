@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aplr/lacuna/filter"
+	"github.com/aplr/lacuna/pubsub"
+)
+
+const testEnvelope = `{"message":{"data":"aGVsbG8=","attributes":{"type":"order"},"messageId":"42"},"subscription":"projects/test/subscriptions/service_orders"}`
+
+func TestBackoffGrowsExponentially(t *testing.T) {
+	// arrange
+	policy := DeliveryPolicy{MinimumBackoff: time.Second, MaximumBackoff: 5 * time.Second}
+
+	// act
+	first := policy.backoff(1)
+	second := policy.backoff(2)
+	third := policy.backoff(3)
+	fourth := policy.backoff(4)
+
+	// assert
+	if first != time.Second || second != 2*time.Second || third != 4*time.Second {
+		t.Errorf("expected backoff to double, got %s, %s, %s", first, second, third)
+	}
+
+	if fourth != 5*time.Second {
+		t.Errorf("expected backoff to be capped at 5s, got %s", fourth)
+	}
+}
+
+func TestBackoffIsDisabledWithoutRetryPolicy(t *testing.T) {
+	// arrange
+	policy := DeliveryPolicy{DeadLetterTopic: "projects/test/topics/dead-letter"}
+
+	// act
+	backoff := policy.backoff(3)
+
+	// assert
+	if backoff != 0 {
+		t.Errorf("expected backoff to be 0, got %s", backoff)
+	}
+}
+
+func TestParsePushMessageParsesEnvelope(t *testing.T) {
+	// arrange
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	// act
+	message, wrapped := parsePushMessage(req, []byte(testEnvelope))
+
+	// assert
+	if !wrapped {
+		t.Errorf("expected message to be wrapped")
+	}
+
+	if message.ID != "42" || string(message.Data) != "hello" || message.Attributes["type"] != "order" {
+		t.Errorf("expected message to be parsed from envelope, got %+v", message)
+	}
+}
+
+func TestParsePushMessageUsesMessageIDHeaderOfUnwrappedMessages(t *testing.T) {
+	// arrange
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("x-goog-pubsub-message-id", "42")
+
+	// act
+	message, wrapped := parsePushMessage(req, []byte("hello"))
+
+	// assert
+	if wrapped {
+		t.Errorf("expected message not to be wrapped")
+	}
+
+	if message.ID != "42" || string(message.Data) != "hello" {
+		t.Errorf("expected raw message with id '42', got %+v", message)
+	}
+}
+
+func TestServeHTTPDeadLettersMessageAfterMaxDeliveryAttempts(t *testing.T) {
+	// arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	var published []pubsub.Message
+	var topic pubsub.Topic
+
+	proxy := newTestProxy()
+	proxy.pubsub = &mockPubSub{
+		publish: func(ctx context.Context, t pubsub.Topic, messages []pubsub.Message) ([]string, error) {
+			topic = t
+			published = append(published, messages...)
+			return []string{"1"}, nil
+		},
+	}
+	proxy.Register(Route{
+		Project:      "test",
+		Subscription: "service_orders",
+		Endpoint:     target.URL,
+		Delivery:     &DeliveryPolicy{DeadLetterTopic: "projects/test/topics/dead-letter", MaxDeliveryAttempts: 2},
+	})
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader(testEnvelope))
+		res := httptest.NewRecorder()
+		proxy.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// act
+	first := serve()
+	second := serve()
+
+	// assert
+	if first != http.StatusInternalServerError {
+		t.Errorf("expected first attempt to fail with %d, got %d", http.StatusInternalServerError, first)
+	}
+
+	if second != http.StatusNoContent {
+		t.Errorf("expected dead-lettered message to be acknowledged, got %d", second)
+	}
+
+	if len(published) != 1 || topic.Name != "projects/test/topics/dead-letter" {
+		t.Fatalf("expected message to be published to the dead-letter topic, got %v to %s", published, topic.Name)
+	}
+
+	if published[0].Attributes[ATTRIBUTE_DEAD_LETTER_DELIVERY_COUNT] != "2" {
+		t.Errorf("expected delivery count to be '2', got '%s'", published[0].Attributes[ATTRIBUTE_DEAD_LETTER_DELIVERY_COUNT])
+	}
+
+	if published[0].Attributes["type"] != "order" || string(published[0].Data) != "hello" {
+		t.Errorf("expected original message to be dead-lettered, got %+v", published[0])
+	}
+}
+
+func TestServeHTTPResetsAttemptsOnceDelivered(t *testing.T) {
+	// arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	proxy := newTestProxy()
+	route := Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL}
+	proxy.Register(route)
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader(testEnvelope))
+
+	// act
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	// assert
+	if attempt, _ := proxy.attempt(route, "42"); attempt != 1 {
+		t.Errorf("expected attempts to be reset, got attempt %d", attempt)
+	}
+}
+
+func TestServeHTTPAcknowledgesMessagesNotMatchingFilter(t *testing.T) {
+	// arrange
+	calls := 0
+	target := newChaosTestServer(&calls)
+	defer target.Close()
+
+	f, err := filter.Parse(`attributes.type = "payment"`)
+
+	if err != nil {
+		t.Fatalf("expected filter to be valid, got %v", err)
+	}
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL, Filter: f})
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader(testEnvelope))
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusNoContent {
+		t.Errorf("expected filtered message to be acknowledged, got %d", res.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("expected filtered message not to be relayed, got %d calls", calls)
+	}
+}
+
+func TestServeHTTPRejectsRedeliveryBeforeBackoff(t *testing.T) {
+	// arrange
+	calls := 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	now := time.Now()
+
+	proxy := newTestProxy()
+	proxy.now = func() time.Time { return now }
+	proxy.Register(Route{
+		Project:      "test",
+		Subscription: "service_orders",
+		Endpoint:     target.URL,
+		Delivery:     &DeliveryPolicy{MinimumBackoff: 10 * time.Second, MaximumBackoff: time.Minute},
+	})
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader(testEnvelope))
+		res := httptest.NewRecorder()
+		proxy.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// act
+	first := serve()
+	early := serve()
+	now = now.Add(11 * time.Second)
+	retried := serve()
+
+	// assert
+	if first != http.StatusInternalServerError {
+		t.Errorf("expected first attempt to fail with %d, got %d", http.StatusInternalServerError, first)
+	}
+
+	if early != http.StatusTooManyRequests {
+		t.Errorf("expected early redelivery to be rejected with %d, got %d", http.StatusTooManyRequests, early)
+	}
+
+	if retried != http.StatusInternalServerError {
+		t.Errorf("expected redelivery after backoff to be relayed, got %d", retried)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected 2 relayed attempts, got %d", calls)
+	}
+}
+
+func TestAttemptForgetsStaleDeliveries(t *testing.T) {
+	// arrange
+	now := time.Now()
+
+	proxy := newTestProxy()
+	proxy.now = func() time.Time { return now }
+
+	route := Route{Project: "test", Subscription: "service_orders"}
+	proxy.attempt(route, "42")
+
+	// act
+	now = now.Add(DELIVERY_TTL + time.Minute)
+	proxy.attempt(route, "43")
+
+	// assert
+	if _, ok := proxy.deliveries[route.path()]["42"]; ok {
+		t.Errorf("expected stale delivery to be forgotten")
+	}
+
+	if _, ok := proxy.deliveries[route.path()]["43"]; !ok {
+		t.Errorf("expected recent delivery to be kept")
+	}
+}
@@ -0,0 +1,400 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aplr/lacuna/filter"
+	"github.com/aplr/lacuna/pubsub"
+	log "github.com/sirupsen/logrus"
+)
+
+// Route relays push requests of a subscription to the endpoint of its container.
+type Route struct {
+	Container    string
+	Project      string
+	Subscription string
+	Endpoint     string
+	Chaos        Chaos
+	// Retry and dead-letter policy enforced by the proxy, if any
+	Delivery *DeliveryPolicy
+	// Filter messages must match to be relayed, if any
+	Filter filter.Filter
+	// Store messages in the proxy instead of relaying them
+	Capture bool
+}
+
+// path returns the path the route is served at by the proxy.
+func (r Route) path() string {
+	return fmt.Sprintf("/projects/%s/subscriptions/%s", r.Project, r.Subscription)
+}
+
+type Proxy interface {
+	Run(ctx context.Context) error
+	Register(route Route) string
+	Unregister(route Route)
+	SetChaos(project string, subscription string, chaos Chaos) error
+}
+
+// ErrUnknownRoute is returned when no route is registered for a subscription.
+var ErrUnknownRoute = errors.New("no route registered for subscription")
+
+var _ = Proxy(&proxyImpl{})
+
+type proxyImpl struct {
+	Proxy
+
+	log    *log.Entry
+	config *Config
+	pubsub pubsub.PubSub
+	client *http.Client
+	random func() float64
+	now    func() time.Time
+
+	// closed once the first route is registered, which starts the listener
+	started   chan struct{}
+	startOnce sync.Once
+
+	mu     sync.RWMutex
+	routes map[string]Route
+	// deliveries per message ID, by route path
+	deliveries map[string]map[string]*delivery
+	// time deliveries were last pruned
+	pruned   time.Time
+	captures *captures
+}
+
+func NewProxy(config *Config, pubsub pubsub.PubSub) Proxy {
+	log := log.WithField("component", "proxy")
+
+	return &proxyImpl{
+		log:        log,
+		config:     config,
+		pubsub:     pubsub,
+		client:     &http.Client{},
+		random:     rand.Float64,
+		now:        time.Now,
+		started:    make(chan struct{}),
+		routes:     make(map[string]Route),
+		deliveries: make(map[string]map[string]*delivery),
+		captures:   newCaptures(),
+	}
+}
+
+// Run serves push requests until the context is done. Unless the proxy is
+// enabled for all subscriptions, it only listens once the first route is
+// registered, so lacuna does not bind the address if the proxy is not used.
+func (p *proxyImpl) Run(ctx context.Context) error {
+	if !p.config.Enabled {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.started:
+		}
+	}
+
+	server := &http.Server{
+		Addr:    p.config.Address,
+		Handler: p.handler(),
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		p.log.Infof("proxy listening on %s", p.config.Address)
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		p.log.WithError(err).Error("error running proxy")
+		return err
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
+// Register adds the route to the proxy, and returns the
+// endpoint push requests of the subscription are sent to.
+// Routes registered again, e.g. once their container is unpaused,
+// keep the chaos options changed at runtime, which are only reset
+// once the route is unregistered as its container stopped.
+func (p *proxyImpl) Register(route Route) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if existing, ok := p.routes[route.path()]; ok {
+		route.Chaos = existing.Chaos
+	}
+
+	p.routes[route.path()] = route
+
+	p.startOnce.Do(func() {
+		close(p.started)
+	})
+
+	return strings.TrimRight(p.config.URL, "/") + route.path()
+}
+
+func (p *proxyImpl) Unregister(route Route) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.routes, route.path())
+	delete(p.deliveries, route.path())
+}
+
+// SetChaos replaces the faults injected into push requests of the subscription.
+func (p *proxyImpl) SetChaos(project string, subscription string, chaos Chaos) error {
+	if err := chaos.Validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := Route{Project: project, Subscription: subscription}.path()
+
+	route, ok := p.routes[path]
+
+	if !ok {
+		return ErrUnknownRoute
+	}
+
+	route.Chaos = chaos
+	p.routes[path] = route
+
+	p.log.WithField("container", route.Container).WithField("subscription", route.Subscription).WithField("chaos", chaos).Info("chaos updated")
+
+	return nil
+}
+
+// handler serves push requests, the API to change chaos options at
+// runtime and the API to access messages captured by sinks.
+func (p *proxyImpl) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/projects/", p)
+	mux.HandleFunc("/chaos/projects/", p.serveChaos)
+	mux.HandleFunc("/captures/projects/", p.serveCaptures)
+
+	return mux
+}
+
+func (p *proxyImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	route, ok := p.routes[r.URL.Path]
+	p.mu.RUnlock()
+
+	if !ok {
+		p.log.WithField("path", r.URL.Path).Warn("no route for push request")
+		http.NotFound(w, r)
+		return
+	}
+
+	log := p.log.WithField("container", route.Container).WithField("subscription", route.Subscription).WithField("method", r.Method)
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		log.WithError(err).Error("error reading push request")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message, wrapped := parsePushMessage(r, body)
+
+	// attributes of unwrapped messages are unknown, so filters can't be applied.
+	// Like pub/sub, messages not matching the filter are acknowledged.
+	if route.Filter != nil && wrapped && !route.Filter.Match(message.Attributes) {
+		log.WithField("message_id", message.ID).Debug("message filtered")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if route.Capture {
+		p.capture(w, route, message)
+		return
+	}
+
+	attempt, ok := p.attempt(route, message.ID)
+
+	log = log.WithField("message_id", message.ID).WithField("attempt", attempt)
+
+	if !ok {
+		log.Debug("rejecting redelivery before retry backoff")
+		http.Error(w, "redelivery before retry backoff", http.StatusTooManyRequests)
+		return
+	}
+
+	if route.Chaos.Latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(route.Chaos.Latency):
+		}
+	}
+
+	if p.random() < route.Chaos.ErrorRate {
+		log.WithField("request_body", p.truncate(body)).Info("chaos: push request failed")
+		p.fail(w, r, log, route, message, attempt, http.StatusInternalServerError, "chaos: injected error")
+		return
+	}
+
+	start := time.Now()
+
+	res, resBody, err := p.relay(r, route, body)
+
+	latency := time.Since(start)
+
+	if err != nil {
+		log.WithError(err).WithField("latency", latency).WithField("request_body", p.truncate(body)).Warn("push delivery failed")
+		p.fail(w, r, log, route, message, attempt, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	if p.random() < route.Chaos.DuplicateRate {
+		if res, _, err := p.relay(r, route, body); err != nil {
+			log.WithError(err).Warn("chaos: duplicate push delivery failed")
+		} else {
+			log.WithField("status", res.StatusCode).Info("chaos: push request duplicated")
+		}
+	}
+
+	if p.random() < route.Chaos.DropRate {
+		log.WithField("status", res.StatusCode).WithField("latency", latency).Info("chaos: push response dropped")
+		p.fail(w, r, log, route, message, attempt, http.StatusServiceUnavailable, "chaos: response dropped")
+		return
+	}
+
+	log = log.WithField("status", res.StatusCode).WithField("latency", latency).WithField("request_body", p.truncate(body)).WithField("response_body", p.truncate(resBody))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		log.Info("push delivered")
+		p.delivered(route, message.ID)
+	} else {
+		log.Warn("push delivery failed")
+
+		if p.failed(r, log, route, message, attempt) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	for key, values := range res.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	w.WriteHeader(res.StatusCode)
+	w.Write(resBody)
+}
+
+// fail answers a failed delivery attempt with the given error, unless the
+// message was dead-lettered, in which case it is acknowledged instead.
+func (p *proxyImpl) fail(w http.ResponseWriter, r *http.Request, log *log.Entry, route Route, message pubsub.Message, attempt int, status int, reason string) {
+	if p.failed(r, log, route, message, attempt) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	http.Error(w, reason, status)
+}
+
+// relay sends the push request to the endpoint of the route,
+// and returns the response along with its body.
+func (p *proxyImpl) relay(r *http.Request, route Route, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, route.Endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header = r.Header.Clone()
+
+	res, err := p.client.Do(req)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, resBody, nil
+}
+
+// serveChaos returns the chaos options of a subscription on GET,
+// replaces them on PUT and removes them on DELETE.
+func (p *proxyImpl) serveChaos(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/chaos")
+
+	p.mu.RLock()
+	route, ok := p.routes[path]
+	p.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, ErrUnknownRoute.Error(), http.StatusNotFound)
+		return
+	}
+
+	var chaos Chaos
+
+	switch r.Method {
+	case http.MethodGet:
+		chaos = route.Chaos
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&chaos); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		if err := p.SetChaos(route.Project, route.Subscription, chaos); errors.Is(err, ErrUnknownRoute) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chaos)
+}
+
+// truncate shortens bodies to the configured limit for logging.
+func (p *proxyImpl) truncate(body []byte) string {
+	if len(body) <= p.config.BodyLimit {
+		return string(body)
+	}
+
+	return fmt.Sprintf("%s... (%d bytes)", body[:p.config.BodyLimit], len(body))
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProxy() *proxyImpl {
+	return NewProxy(&Config{URL: "http://lacuna:8090/", BodyLimit: 4}, nil).(*proxyImpl)
+}
+
+func TestRegisterReturnsProxyEndpoint(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+
+	// act
+	endpoint := proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders"})
+
+	// assert
+	if endpoint != "http://lacuna:8090/projects/test/subscriptions/service_orders" {
+		t.Errorf("expected endpoint to be 'http://lacuna:8090/projects/test/subscriptions/service_orders', got '%s'", endpoint)
+	}
+}
+
+func TestServeHTTPRelaysRequestToEndpoint(t *testing.T) {
+	// arrange
+	var received string
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = r.Header.Get("X-Test") + ":" + string(body)
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL + "/orders"})
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader("message"))
+	req.Header.Set("X-Test", "header")
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.ServeHTTP(res, req)
+
+	// assert
+	if received != "header:message" {
+		t.Errorf("expected request to be relayed, got '%s'", received)
+	}
+
+	if res.Code != http.StatusAccepted {
+		t.Errorf("expected status to be %d, got %d", http.StatusAccepted, res.Code)
+	}
+
+	if res.Body.String() != "ok" {
+		t.Errorf("expected response body to be 'ok', got '%s'", res.Body.String())
+	}
+}
+
+func TestServeHTTPReturnsNotFoundForUnknownRoute(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders"})
+	proxy.Unregister(Route{Project: "test", Subscription: "service_orders"})
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader("message"))
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusNotFound {
+		t.Errorf("expected status to be %d, got %d", http.StatusNotFound, res.Code)
+	}
+}
+
+func TestServeHTTPReturnsBadGatewayIfEndpointIsUnreachable(t *testing.T) {
+	// arrange
+	target := httptest.NewServer(http.NotFoundHandler())
+	target.Close()
+
+	proxy := newTestProxy()
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: target.URL})
+
+	req := httptest.NewRequest(http.MethodPost, "/projects/test/subscriptions/service_orders", strings.NewReader("message"))
+	res := httptest.NewRecorder()
+
+	// act
+	proxy.ServeHTTP(res, req)
+
+	// assert
+	if res.Code != http.StatusBadGateway {
+		t.Errorf("expected status to be %d, got %d", http.StatusBadGateway, res.Code)
+	}
+}
+
+func TestTruncateShortensLongBodies(t *testing.T) {
+	// arrange
+	proxy := newTestProxy()
+
+	// act
+	short := proxy.truncate([]byte("abc"))
+	long := proxy.truncate([]byte("abcdefgh"))
+
+	// assert
+	if short != "abc" {
+		t.Errorf("expected short body to be unchanged, got '%s'", short)
+	}
+
+	if long != "abcd... (8 bytes)" {
+		t.Errorf("expected long body to be truncated, got '%s'", long)
+	}
+}
+
+func TestRunListensOnceFirstRouteIsRegistered(t *testing.T) {
+	// arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+
+	proxy := NewProxy(&Config{Address: address, URL: "http://lacuna:8090"}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go proxy.Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+
+	if conn, err := net.Dial("tcp", address); err == nil {
+		conn.Close()
+		t.Fatalf("expected proxy not to listen before a route is registered")
+	}
+
+	// act
+	proxy.Register(Route{Project: "test", Subscription: "service_orders", Endpoint: "http://service/orders"})
+
+	// assert
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		conn, err := net.Dial("tcp", address)
+
+		if err == nil {
+			conn.Close()
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected proxy to listen once a route is registered, got %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/aplr/lacuna/pubsub"
+)
+
+var _ = pubsub.PubSub(&mockPubSub{})
+
+type mockPubSub struct {
+	pubsub.PubSub
+
+	publish func(ctx context.Context, topic pubsub.Topic, messages []pubsub.Message) ([]string, error)
+}
+
+func (ps *mockPubSub) Publish(ctx context.Context, topic pubsub.Topic, messages []pubsub.Message) ([]string, error) {
+	if ps.publish == nil {
+		panic("no mock function provided")
+	}
+
+	return ps.publish(ctx, topic, messages)
+}
@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	gcps "cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// clients holds a pub/sub and schema client per project, which are created
+// on first use. The emulator accepts any project, while in production topics
+// and subscriptions may be spread across several projects.
+type clients struct {
+	mu      sync.Mutex
+	pubsub  map[string]*gcps.Client
+	schemas map[string]*gcps.SchemaClient
+}
+
+func newClients() *clients {
+	return &clients{
+		pubsub:  make(map[string]*gcps.Client),
+		schemas: make(map[string]*gcps.SchemaClient),
+	}
+}
+
+func (c *clients) client(projectID string) (*gcps.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.pubsub[projectID]; ok {
+		return client, nil
+	}
+
+	// clients outlive the request they are created for, thus they must
+	// not be bound to its context, which is only used for dialing anyways
+	client, err := gcps.NewClient(context.Background(), projectID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.pubsub[projectID] = client
+
+	return client, nil
+}
+
+func (c *clients) schemaClient(projectID string) (*gcps.SchemaClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.schemas[projectID]; ok {
+		return client, nil
+	}
+
+	client, err := gcps.NewSchemaClient(context.Background(), projectID, emulatorOptions()...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.schemas[projectID] = client
+
+	return client, nil
+}
+
+// projects returns the IDs of all projects a client was created for.
+func (c *clients) projects() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	projects := make([]string, 0, len(c.pubsub))
+
+	for projectID := range c.pubsub {
+		projects = append(projects, projectID)
+	}
+
+	return projects
+}
+
+// emulatorOptions connects to the emulator if PUBSUB_EMULATOR_HOST is set, as
+// gcps.NewClient does, since the schema client does not detect it on its own.
+func emulatorOptions() []option.ClientOption {
+	addr := os.Getenv("PUBSUB_EMULATOR_HOST")
+
+	if addr == "" {
+		return nil
+	}
+
+	return []option.ClientOption{
+		option.WithEndpoint(addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithTelemetryDisabled(),
+	}
+}
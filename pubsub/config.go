@@ -1,12 +1,35 @@
 package pubsub
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	ProjectID string `mapstructure:"project_id"`
+	// Additional projects lacuna manages resources in, which are
+	// looked up for orphaned resources on startup
+	Projects []string `mapstructure:"projects"`
+	// Maximum time to wait for pub/sub to answer on startup, or zero to wait forever
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+	// Initial delay between probes while waiting for pub/sub, which is doubled
+	// after every failed probe up to the maximum delay
+	ReadyBackoff    time.Duration `mapstructure:"ready_backoff"`
+	ReadyMaxBackoff time.Duration `mapstructure:"ready_max_backoff"`
+	// Time subscriptions keeping their backlog are kept once their container
+	// stopped, before they are removed as orphans on startup
+	BacklogTTL time.Duration `mapstructure:"backlog_ttl"`
 }
 
+const DEFAULT_BACKLOG_TTL = 7 * 24 * time.Hour
+
 func init() {
 	viper.BindEnv("pubsub_project_id")
 	viper.SetDefault("pubsub.project_id", "pubsub")
+	viper.SetDefault("pubsub.projects", []string{})
+	viper.SetDefault("pubsub.ready_timeout", 2*time.Minute)
+	viper.SetDefault("pubsub.ready_backoff", 500*time.Millisecond)
+	viper.SetDefault("pubsub.ready_max_backoff", 10*time.Second)
+	viper.SetDefault("pubsub.backlog_ttl", DEFAULT_BACKLOG_TTL)
 }
@@ -0,0 +1,226 @@
+package pubsub
+
+import (
+	"time"
+
+	gcps "cloud.google.com/go/pubsub"
+)
+
+// Defaults applied by pub/sub to subscription settings left unset on creation
+const (
+	defaultAckDeadline         = 10 * time.Second
+	defaultRetentionDuration   = 7 * 24 * time.Hour
+	defaultMaxDeliveryAttempts = 5
+	defaultMinimumBackoff      = 10 * time.Second
+	defaultMaximumBackoff      = 600 * time.Second
+)
+
+// subscriptionDiff describes the changes required to bring an existing
+// subscription in line with the desired subscription config.
+type subscriptionDiff struct {
+	// fields which changed and can be updated in place
+	fields []string
+	// reason the subscription has to be recreated, if an immutable field changed
+	recreate string
+	// update to apply to the subscription, contains all changed mutable fields
+	update gcps.SubscriptionConfigToUpdate
+}
+
+func (diff *subscriptionDiff) changed() bool {
+	return diff.recreate != "" || len(diff.fields) > 0
+}
+
+func diffSubscriptionConfig(current gcps.SubscriptionConfig, desired gcps.SubscriptionConfig) subscriptionDiff {
+	var diff subscriptionDiff
+
+	// immutable fields, the subscription has to be recreated if these changed
+	switch {
+	case current.Topic.String() != desired.Topic.String():
+		diff.recreate = "topic changed"
+	case current.EnableMessageOrdering != desired.EnableMessageOrdering:
+		diff.recreate = "message ordering changed"
+	case current.Filter != desired.Filter:
+		diff.recreate = "filter changed"
+	}
+
+	if !pushConfigEqual(current.PushConfig, desired.PushConfig) {
+		diff.fields = append(diff.fields, "push_config")
+		diff.update.PushConfig = &desired.PushConfig
+	}
+
+	if ackDeadline := durationOrDefault(desired.AckDeadline, defaultAckDeadline); current.AckDeadline != ackDeadline {
+		diff.fields = append(diff.fields, "ack_deadline")
+		diff.update.AckDeadline = ackDeadline
+	}
+
+	if current.RetainAckedMessages != desired.RetainAckedMessages {
+		diff.fields = append(diff.fields, "retain_acked_messages")
+		diff.update.RetainAckedMessages = desired.RetainAckedMessages
+	}
+
+	if retention := durationOrDefault(desired.RetentionDuration, defaultRetentionDuration); current.RetentionDuration != retention {
+		diff.fields = append(diff.fields, "retention_duration")
+		diff.update.RetentionDuration = retention
+	}
+
+	if expiration := optionalDuration(desired.ExpirationPolicy); optionalDuration(current.ExpirationPolicy) != expiration {
+		diff.fields = append(diff.fields, "expiration_policy")
+		diff.update.ExpirationPolicy = expiration
+	}
+
+	if !deadLetterPolicyEqual(current.DeadLetterPolicy, desired.DeadLetterPolicy) {
+		diff.fields = append(diff.fields, "dead_letter_policy")
+		diff.update.DeadLetterPolicy = &gcps.DeadLetterPolicy{}
+
+		// the zero value removes dead lettering from the subscription
+		if desired.DeadLetterPolicy != nil {
+			diff.update.DeadLetterPolicy = desired.DeadLetterPolicy
+		}
+	}
+
+	if !retryPolicyEqual(current.RetryPolicy, desired.RetryPolicy) {
+		diff.fields = append(diff.fields, "retry_policy")
+		diff.update.RetryPolicy = &gcps.RetryPolicy{}
+
+		// the zero value removes the retry policy from the subscription
+		if desired.RetryPolicy != nil {
+			diff.update.RetryPolicy = desired.RetryPolicy
+		}
+	}
+
+	if !labelsEqual(current.Labels, desired.Labels) {
+		diff.fields = append(diff.fields, "labels")
+		diff.update.Labels = desired.Labels
+
+		// an empty, non-nil map removes all labels from the subscription
+		if desired.Labels == nil {
+			diff.update.Labels = map[string]string{}
+		}
+	}
+
+	if current.EnableExactlyOnceDelivery != desired.EnableExactlyOnceDelivery {
+		diff.fields = append(diff.fields, "enable_exactly_once_delivery")
+		diff.update.EnableExactlyOnceDelivery = desired.EnableExactlyOnceDelivery
+	}
+
+	return diff
+}
+
+func pushConfigEqual(current gcps.PushConfig, desired gcps.PushConfig) bool {
+	if current.Endpoint != desired.Endpoint {
+		return false
+	}
+
+	// other push settings are irrelevant for pull subscriptions
+	if desired.Endpoint == "" {
+		return true
+	}
+
+	for key, value := range desired.Attributes {
+		if current.Attributes[key] != value {
+			return false
+		}
+	}
+
+	// pub/sub sets the api version attribute if it was not provided
+	for key := range current.Attributes {
+		if _, ok := desired.Attributes[key]; !ok && key != "x-goog-version" {
+			return false
+		}
+	}
+
+	currentToken, _ := current.AuthenticationMethod.(*gcps.OIDCToken)
+	desiredToken, _ := desired.AuthenticationMethod.(*gcps.OIDCToken)
+
+	if (currentToken == nil) != (desiredToken == nil) {
+		return false
+	}
+
+	if desiredToken != nil {
+		// the audience defaults to the endpoint if it was not provided
+		audience := desiredToken.Audience
+		if audience == "" && currentToken.Audience == desired.Endpoint {
+			audience = desired.Endpoint
+		}
+
+		if currentToken.ServiceAccountEmail != desiredToken.ServiceAccountEmail || currentToken.Audience != audience {
+			return false
+		}
+	}
+
+	currentWrapper, _ := current.Wrapper.(*gcps.NoWrapper)
+	desiredWrapper, _ := desired.Wrapper.(*gcps.NoWrapper)
+
+	if (currentWrapper == nil) != (desiredWrapper == nil) {
+		return false
+	}
+
+	return desiredWrapper == nil || currentWrapper.WriteMetadata == desiredWrapper.WriteMetadata
+}
+
+func labelsEqual(current map[string]string, desired map[string]string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+
+	for key, value := range desired {
+		if current[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func schemaSettingsEqual(current *gcps.SchemaSettings, desired *gcps.SchemaSettings) bool {
+	if current == nil || desired == nil {
+		return current == desired
+	}
+
+	return current.Schema == desired.Schema && current.Encoding == desired.Encoding
+}
+
+func deadLetterPolicyEqual(current *gcps.DeadLetterPolicy, desired *gcps.DeadLetterPolicy) bool {
+	if current == nil || desired == nil {
+		return current == desired
+	}
+
+	maxDeliveryAttempts := desired.MaxDeliveryAttempts
+	if maxDeliveryAttempts == 0 {
+		maxDeliveryAttempts = defaultMaxDeliveryAttempts
+	}
+
+	return current.DeadLetterTopic == desired.DeadLetterTopic && current.MaxDeliveryAttempts == maxDeliveryAttempts
+}
+
+func retryPolicyEqual(current *gcps.RetryPolicy, desired *gcps.RetryPolicy) bool {
+	// an empty retry policy is equal to no retry policy at all
+	if desired != nil && desired.MinimumBackoff == nil && desired.MaximumBackoff == nil {
+		desired = nil
+	}
+
+	if current == nil || desired == nil {
+		return current == desired
+	}
+
+	return durationOrDefault(optionalDuration(current.MinimumBackoff), defaultMinimumBackoff) == durationOrDefault(optionalDuration(desired.MinimumBackoff), defaultMinimumBackoff) &&
+		durationOrDefault(optionalDuration(current.MaximumBackoff), defaultMaximumBackoff) == durationOrDefault(optionalDuration(desired.MaximumBackoff), defaultMaximumBackoff)
+}
+
+// optionalDuration unwraps the optional durations used by the pub/sub client,
+// which are either nil or hold a time.Duration.
+func optionalDuration(duration interface{}) time.Duration {
+	if d, ok := duration.(time.Duration); ok {
+		return d
+	}
+
+	return 0
+}
+
+func durationOrDefault(duration time.Duration, defaultDuration time.Duration) time.Duration {
+	if duration == 0 {
+		return defaultDuration
+	}
+
+	return duration
+}
@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gcps "cloud.google.com/go/pubsub"
+)
+
+func newTestTopic(t *testing.T, name string) *gcps.Topic {
+	client, err := gcps.NewClient(context.Background(), "test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client.Topic(name)
+}
+
+// newServerConfig returns the config pub/sub reports for a subscription
+// created from the given config, including the defaults it applies.
+func newServerConfig(config gcps.SubscriptionConfig) gcps.SubscriptionConfig {
+	config.AckDeadline = durationOrDefault(config.AckDeadline, defaultAckDeadline)
+	config.RetentionDuration = durationOrDefault(config.RetentionDuration, defaultRetentionDuration)
+	config.RetryPolicy = nil
+
+	return config
+}
+
+func TestDiffSubscriptionConfigReportsNoChanges(t *testing.T) {
+	// arrange
+	desired := createSubscriptionConfig(newTestTopic(t, "test"), nil, Subscription{
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "test",
+		Endpoint: "http://test/messages",
+	})
+
+	// act
+	diff := diffSubscriptionConfig(newServerConfig(desired), desired)
+
+	// assert
+	if diff.changed() {
+		t.Errorf("expected no changes, got fields %v and recreate reason '%s'", diff.fields, diff.recreate)
+	}
+}
+
+func TestDiffSubscriptionConfigUpdatesMutableFields(t *testing.T) {
+	// arrange
+	topic := newTestTopic(t, "test")
+	current := newServerConfig(createSubscriptionConfig(topic, nil, Subscription{
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "test",
+		Endpoint: "http://test/messages",
+	}))
+	desired := createSubscriptionConfig(topic, nil, Subscription{
+		Type:        SUBSCRIPTION_TYPE_PUSH,
+		Topic:       "test",
+		Endpoint:    "http://other/messages",
+		AckDeadline: 30 * time.Second,
+	})
+
+	// act
+	diff := diffSubscriptionConfig(current, desired)
+
+	// assert
+	if diff.recreate != "" {
+		t.Errorf("expected subscription not to be recreated, got reason '%s'", diff.recreate)
+	}
+
+	if len(diff.fields) != 2 {
+		t.Fatalf("expected 2 changed fields, got %v", diff.fields)
+	}
+
+	if diff.update.PushConfig == nil || diff.update.PushConfig.Endpoint != "http://other/messages" {
+		t.Errorf("expected push endpoint to be updated to 'http://other/messages'")
+	}
+
+	if diff.update.AckDeadline != 30*time.Second {
+		t.Errorf("expected ack deadline to be updated to 30s, got %v", diff.update.AckDeadline)
+	}
+}
+
+func TestDiffSubscriptionConfigRecreatesOnImmutableChange(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "test",
+		Endpoint: "http://test/messages",
+	}
+	current := newServerConfig(createSubscriptionConfig(newTestTopic(t, "test"), nil, subscription))
+
+	filtered := subscription
+	filtered.Filter = `attributes.type = "test"`
+
+	ordered := subscription
+	ordered.EnableOrdering = true
+
+	tests := map[string]gcps.SubscriptionConfig{
+		"topic changed":            createSubscriptionConfig(newTestTopic(t, "other"), nil, subscription),
+		"filter changed":           createSubscriptionConfig(newTestTopic(t, "test"), nil, filtered),
+		"message ordering changed": createSubscriptionConfig(newTestTopic(t, "test"), nil, ordered),
+	}
+
+	for reason, desired := range tests {
+		// act
+		diff := diffSubscriptionConfig(current, desired)
+
+		// assert
+		if diff.recreate != reason {
+			t.Errorf("expected recreate reason to be '%s', got '%s'", reason, diff.recreate)
+		}
+	}
+}
+
+func TestDiffSubscriptionConfigDetachesPushEndpoint(t *testing.T) {
+	// arrange
+	topic := newTestTopic(t, "test")
+	current := newServerConfig(createSubscriptionConfig(topic, nil, Subscription{
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "test",
+		Endpoint: "http://test/messages",
+	}))
+	desired := createSubscriptionConfig(topic, nil, Subscription{
+		Type:  SUBSCRIPTION_TYPE_PULL,
+		Topic: "test",
+	})
+
+	// act
+	diff := diffSubscriptionConfig(current, desired)
+
+	// assert
+	if diff.update.PushConfig == nil || diff.update.PushConfig.Endpoint != "" {
+		t.Errorf("expected push config to be reset to pull")
+	}
+}
@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels attached to topics and subscriptions managed by lacuna
+const (
+	LABEL_MANAGED_BY      = "managed-by"
+	LABEL_CONTAINER       = "lacuna-container"
+	LABEL_COMPOSE_PROJECT = "lacuna-compose-project"
+	LABEL_TAIL_HOST       = "lacuna-tail-host"
+	LABEL_TAIL_PID        = "lacuna-tail-pid"
+	LABEL_KEEP_BACKLOG    = "lacuna-keep-backlog"
+	LABEL_SUSPENDED_AT    = "lacuna-suspended-at"
+
+	MANAGED_BY_LACUNA = "lacuna"
+)
+
+var (
+	// Label values may only contain lowercase letters, digits, underscores and dashes
+	invalidLabelValueRegex = regexp.MustCompile(`[^a-z0-9_-]`)
+	maxLabelValueLength    = 63
+	shortContainerIDLength = 12
+)
+
+// OwnershipLabels returns the labels identifying resources managed by lacuna
+// on behalf of a container. The container ID is shortened, like docker does,
+// to fit the label value length limit.
+func OwnershipLabels(containerID string, composeProject string) map[string]string {
+	if len(containerID) > shortContainerIDLength {
+		containerID = containerID[:shortContainerIDLength]
+	}
+
+	labels := map[string]string{
+		LABEL_MANAGED_BY: MANAGED_BY_LACUNA,
+		LABEL_CONTAINER:  sanitizeLabelValue(containerID),
+	}
+
+	if composeProject != "" {
+		labels[LABEL_COMPOSE_PROJECT] = sanitizeLabelValue(composeProject)
+	}
+
+	return labels
+}
+
+// IsManaged reports whether a resource with the given labels is managed by lacuna.
+func IsManaged(labels map[string]string) bool {
+	return labels[LABEL_MANAGED_BY] == MANAGED_BY_LACUNA
+}
+
+// isTail reports whether a resource with the given labels
+// is a temporary subscription created by 'lacuna tail'.
+func isTail(labels map[string]string) bool {
+	return labels[LABEL_TAIL_PID] != ""
+}
+
+// keepsBacklog reports whether a resource with the given labels is a
+// subscription which must outlive its container to keep its backlog.
+func keepsBacklog(labels map[string]string) bool {
+	return labels[LABEL_KEEP_BACKLOG] == "true"
+}
+
+// suspendedAt returns the time a subscription keeping its backlog was
+// suspended, and false if it was not suspended by lacuna.
+func suspendedAt(labels map[string]string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(labels[LABEL_SUSPENDED_AT], 10, 64)
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(seconds, 0), true
+}
+
+// backlogExpired reports whether a subscription keeping its backlog
+// was suspended for longer than the given ttl.
+func backlogExpired(labels map[string]string, ttl time.Duration) bool {
+	suspended, ok := suspendedAt(labels)
+
+	return ok && time.Since(suspended) >= ttl
+}
+
+// suspendedLabels returns the labels marking a subscription
+// keeping its backlog as suspended at the given time.
+func suspendedLabels(labels map[string]string, at time.Time) map[string]string {
+	suspended := make(map[string]string, len(labels)+1)
+
+	for key, value := range labels {
+		suspended[key] = value
+	}
+
+	suspended[LABEL_SUSPENDED_AT] = strconv.FormatInt(at.Unix(), 10)
+
+	return suspended
+}
+
+// isOwnedBy reports whether a resource with the given labels
+// was created on behalf of the container with the given ID.
+func isOwnedBy(labels map[string]string, containerID string) bool {
+	owner := labels[LABEL_CONTAINER]
+
+	return owner != "" && strings.HasPrefix(containerID, owner)
+}
+
+func isOwnedByAny(labels map[string]string, containerIDs []string) bool {
+	for _, containerID := range containerIDs {
+		if isOwnedBy(labels, containerID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sanitizeLabelValue(value string) string {
+	value = invalidLabelValueRegex.ReplaceAllString(strings.ToLower(value), "-")
+
+	if len(value) > maxLabelValueLength {
+		value = value[:maxLabelValueLength]
+	}
+
+	return value
+}
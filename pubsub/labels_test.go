@@ -0,0 +1,43 @@
+package pubsub
+
+import "testing"
+
+func TestOwnershipLabelsShortensContainerID(t *testing.T) {
+	// arrange
+	containerID := "4f66ad9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a"
+
+	// act
+	labels := OwnershipLabels(containerID, "My.Project")
+
+	// assert
+	if labels[LABEL_MANAGED_BY] != MANAGED_BY_LACUNA {
+		t.Errorf("expected managed-by label to be 'lacuna', got '%s'", labels[LABEL_MANAGED_BY])
+	}
+
+	if labels[LABEL_CONTAINER] != "4f66ad9a0b2e" {
+		t.Errorf("expected container label to be '4f66ad9a0b2e', got '%s'", labels[LABEL_CONTAINER])
+	}
+
+	if labels[LABEL_COMPOSE_PROJECT] != "my-project" {
+		t.Errorf("expected compose project label to be 'my-project', got '%s'", labels[LABEL_COMPOSE_PROJECT])
+	}
+}
+
+func TestIsOwnedByMatchesFullContainerID(t *testing.T) {
+	// arrange
+	containerID := "4f66ad9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a0b2e1c3d5e7f9a"
+	labels := OwnershipLabels(containerID, "")
+
+	// act
+	owned := isOwnedBy(labels, containerID)
+	other := isOwnedBy(labels, "1c3d5e7f9a0b")
+
+	// assert
+	if !owned {
+		t.Errorf("expected resource to be owned by container")
+	}
+
+	if other {
+		t.Errorf("expected resource not to be owned by other container")
+	}
+}
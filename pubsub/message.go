@@ -0,0 +1,143 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	gcps "cloud.google.com/go/pubsub"
+)
+
+type Message struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	PublishTime time.Time
+}
+
+// jsonMessage is the JSON representation of a message. Data is written as
+// a string if it is valid UTF-8, and base64 encoded otherwise.
+type jsonMessage struct {
+	ID          string            `json:"id,omitempty"`
+	Data        json.RawMessage   `json:"data,omitempty"`
+	DataBase64  []byte            `json:"data_base64,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"ordering_key,omitempty"`
+	PublishTime *time.Time        `json:"publish_time,omitempty"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	msg := jsonMessage{
+		ID:          m.ID,
+		Attributes:  m.Attributes,
+		OrderingKey: m.OrderingKey,
+	}
+
+	if utf8.Valid(m.Data) {
+		data, err := json.Marshal(string(m.Data))
+
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Data = data
+	} else {
+		msg.DataBase64 = m.Data
+	}
+
+	if !m.PublishTime.IsZero() {
+		msg.PublishTime = &m.PublishTime
+	}
+
+	return json.Marshal(msg)
+}
+
+// UnmarshalJSON reads a message from its JSON representation. Besides a
+// string, data may be any JSON value, which is used as the message data.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var msg jsonMessage
+
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return err
+	}
+
+	if len(msg.Data) > 0 && len(msg.DataBase64) > 0 {
+		return fmt.Errorf("only one of data and data_base64 may be set")
+	}
+
+	*m = Message{
+		ID:          msg.ID,
+		Data:        msg.DataBase64,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(msg.Data), []byte(`"`)) {
+		var data string
+
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return err
+		}
+
+		m.Data = []byte(data)
+	} else if len(msg.Data) > 0 {
+		m.Data = msg.Data
+	}
+
+	if msg.PublishTime != nil {
+		m.PublishTime = *msg.PublishTime
+	}
+
+	return nil
+}
+
+func (ps *pubSubImpl) Publish(ctx context.Context, topic Topic, messages []Message) ([]string, error) {
+	projectID, topicID := topic.Resolve(ps.projectID)
+
+	log := ps.log.WithField("topic", topicID).WithField("project", projectID)
+
+	t, err := ps.ensureTopic(ctx, topic, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer t.Stop()
+
+	for _, message := range messages {
+		if message.OrderingKey != "" {
+			t.EnableMessageOrdering = true
+		}
+	}
+
+	results := make([]*gcps.PublishResult, 0, len(messages))
+
+	for _, message := range messages {
+		results = append(results, t.Publish(ctx, &gcps.Message{
+			Data:        message.Data,
+			Attributes:  message.Attributes,
+			OrderingKey: message.OrderingKey,
+		}))
+	}
+
+	ids := make([]string, 0, len(results))
+
+	for _, result := range results {
+		id, err := result.Get(ctx)
+
+		if err != nil {
+			log.WithError(err).Error("error publishing message")
+			return ids, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	log.WithField("count", len(ids)).Debug("messages published")
+
+	return ids, nil
+}
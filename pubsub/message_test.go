@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageMarshalsTextData(t *testing.T) {
+	// arrange
+	message := Message{Data: []byte("hello"), OrderingKey: "key"}
+
+	// act
+	b, err := json.Marshal(message)
+
+	// assert
+	if err != nil {
+		t.Errorf("expected err to be nil, got %v", err)
+	}
+
+	if string(b) != `{"data":"hello","ordering_key":"key"}` {
+		t.Errorf("expected text data, got %s", b)
+	}
+}
+
+func TestMessageMarshalsBinaryDataAsBase64(t *testing.T) {
+	// arrange
+	message := Message{Data: []byte{0xff, 0xfe}}
+
+	// act
+	b, err := json.Marshal(message)
+
+	// assert
+	if err != nil {
+		t.Errorf("expected err to be nil, got %v", err)
+	}
+
+	if string(b) != `{"data_base64":"//4="}` {
+		t.Errorf("expected base64 data, got %s", b)
+	}
+}
+
+func TestMessageUnmarshalsJSONData(t *testing.T) {
+	// arrange
+	var message Message
+
+	// act
+	err := json.Unmarshal([]byte(`{"data":{"id":1},"attributes":{"type":"order"}}`), &message)
+
+	// assert
+	if err != nil {
+		t.Errorf("expected err to be nil, got %v", err)
+	}
+
+	if string(message.Data) != `{"id":1}` {
+		t.Errorf("expected data to be the raw JSON value, got %s", message.Data)
+	}
+
+	if message.Attributes["type"] != "order" {
+		t.Errorf("expected attribute 'type' to be 'order', got '%s'", message.Attributes["type"])
+	}
+}
+
+func TestMessageRoundTripsThroughJSON(t *testing.T) {
+	// arrange
+	message := Message{Data: []byte("hello"), Attributes: map[string]string{"a": "b"}}
+
+	// act
+	b, _ := json.Marshal(message)
+
+	var decoded Message
+	err := json.Unmarshal(b, &decoded)
+
+	// assert
+	if err != nil {
+		t.Errorf("expected err to be nil, got %v", err)
+	}
+
+	if string(decoded.Data) != "hello" || decoded.Attributes["a"] != "b" {
+		t.Errorf("expected message to be unchanged, got %+v", decoded)
+	}
+}
+
+func TestMessageRejectsDataAndBase64Data(t *testing.T) {
+	// arrange
+	var message Message
+
+	// act
+	err := json.Unmarshal([]byte(`{"data":"a","data_base64":"YQ=="}`), &message)
+
+	// assert
+	if err == nil {
+		t.Errorf("expected err to be set")
+	}
+}
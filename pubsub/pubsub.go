@@ -2,21 +2,43 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"time"
 
 	gcps "cloud.google.com/go/pubsub"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type PubSub interface {
+	Ping(ctx context.Context) error
+	CreateTopic(ctx context.Context, topic Topic) error
+	DeleteTopic(ctx context.Context, topic Topic) error
 	CreateSubscription(ctx context.Context, subscription Subscription) error
 	DeleteSubscription(ctx context.Context, subscription Subscription) error
+	SuspendSubscription(ctx context.Context, subscription Subscription) error
+	DeleteOrphanedSubscriptions(ctx context.Context, containerIDs []string) error
+	SeekToTime(ctx context.Context, subscription Subscription, to time.Time) error
+	SeekToSnapshot(ctx context.Context, subscription Subscription, snapshot string) error
+	CreateSnapshot(ctx context.Context, subscription Subscription, snapshot string) (*Snapshot, error)
+	ListSnapshots(ctx context.Context, projectID string) ([]Snapshot, error)
+	DeleteSnapshot(ctx context.Context, snapshot Snapshot) error
+	Publish(ctx context.Context, topic Topic, messages []Message) ([]string, error)
+	Receive(ctx context.Context, subscription Subscription, handler func(Message)) error
+	DeleteStaleTailSubscriptions(ctx context.Context, projectID string, host string, alive func(pid int) bool) error
 }
 
 type pubSubImpl struct {
 	PubSub
 
-	log    *log.Entry
-	client *gcps.Client
+	log        *log.Entry
+	projectID  string
+	projects   []string
+	backlogTTL time.Duration
+	clients    *clients
 }
 
 func NewPubSub(ctx context.Context, config *Config) (PubSub, error) {
@@ -26,24 +48,142 @@ func NewPubSub(ctx context.Context, config *Config) (PubSub, error) {
 		return nil, err
 	}
 
-	return NewPubSubWithClient(client), nil
+	schemaClient, err := gcps.NewSchemaClient(ctx, config.ProjectID, emulatorOptions()...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ps := NewPubSubWithClient(client, schemaClient).(*pubSubImpl)
+	ps.projects = config.Projects
+	ps.backlogTTL = config.BacklogTTL
+
+	return ps, nil
 }
 
-func NewPubSubWithClient(client *gcps.Client) PubSub {
+// NewPubSubWithClient creates a pubsub using the given clients for the
+// project of the client, clients for other projects are created on demand.
+func NewPubSubWithClient(client *gcps.Client, schemaClient *gcps.SchemaClient) PubSub {
 	log := log.WithField("component", "pubsub")
 
+	clients := newClients()
+	clients.pubsub[client.Project()] = client
+	clients.schemas[client.Project()] = schemaClient
+
 	return &pubSubImpl{
-		log:    log,
-		client: client,
+		log:        log,
+		projectID:  client.Project(),
+		backlogTTL: DEFAULT_BACKLOG_TTL,
+		clients:    clients,
 	}
 }
 
-func (ps *pubSubImpl) ensureTopic(ctx context.Context, topicName string) (*gcps.Topic, error) {
-	log := ps.log.WithField("topic", topicName)
+// Ping checks whether pub/sub answers requests, which
+// fails while the emulator is still starting up.
+func (ps *pubSubImpl) Ping(ctx context.Context) error {
+	client, err := ps.clients.client(ps.projectID)
 
-	topic := ps.client.Topic(topicName)
+	if err != nil {
+		return err
+	}
 
-	exists, err := topic.Exists(ctx)
+	if _, err := client.Topics(ctx).Next(); err != nil && err != iterator.Done {
+		return err
+	}
+
+	return nil
+}
+
+// ensureSchema creates the topic's schema if it does not exist yet, or commits
+// a new revision if the schema definition changed, and returns the schema
+// settings to attach to the topic.
+func (ps *pubSubImpl) ensureSchema(ctx context.Context, topic Topic) (*gcps.SchemaSettings, error) {
+	projectID, _ := topic.Resolve(ps.projectID)
+
+	log := ps.log.WithField("topic", topic.Name).WithField("schema", topic.Schema).WithField("project", projectID)
+
+	definition, err := os.ReadFile(topic.SchemaFile)
+
+	if err != nil {
+		log.WithError(err).Error("error reading schema definition")
+		return nil, err
+	}
+
+	schemaClient, err := ps.clients.schemaClient(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating schema client")
+		return nil, err
+	}
+
+	config := gcps.SchemaConfig{
+		Name:       fmt.Sprintf("projects/%s/schemas/%s", projectID, topic.Schema),
+		Type:       mapSchemaType(topic.SchemaType),
+		Definition: string(definition),
+	}
+
+	schema, err := schemaClient.Schema(ctx, topic.Schema, gcps.SchemaViewFull)
+
+	if status.Code(err) == codes.NotFound {
+		schema, err = schemaClient.CreateSchema(ctx, topic.Schema, config)
+
+		if err != nil {
+			log.WithError(err).Error("error creating schema")
+			return nil, err
+		}
+
+		log.Debug("schema created")
+	} else if err != nil {
+		log.WithError(err).Error("error getting schema")
+		return nil, err
+	} else if schema.Type != config.Type || schema.Definition != config.Definition {
+		schema, err = schemaClient.CommitSchema(ctx, topic.Schema, config)
+
+		if err != nil {
+			log.WithError(err).Error("error committing schema revision")
+			return nil, err
+		}
+
+		log.WithField("revision_id", schema.RevisionID).Debug("schema revision committed")
+	}
+
+	return &gcps.SchemaSettings{
+		Schema:   config.Name,
+		Encoding: mapSchemaEncoding(topic.SchemaEncoding),
+	}, nil
+}
+
+// ensureTopic creates the topic if it does not exist yet. The settings of
+// existing topics are only updated if update is set, which is used for
+// topics declared explicitly, as opposed to topics subscribed to.
+func (ps *pubSubImpl) ensureTopic(ctx context.Context, topic Topic, update bool) (*gcps.Topic, error) {
+	projectID, topicID := topic.Resolve(ps.projectID)
+
+	log := ps.log.WithField("topic", topicID).WithField("project", projectID)
+
+	var schemaSettings *gcps.SchemaSettings
+
+	if topic.HasSchema() {
+		settings, err := ps.ensureSchema(ctx, topic)
+
+		if err != nil {
+			log.WithError(err).Error("error ensuring schema")
+			return nil, err
+		}
+
+		schemaSettings = settings
+	}
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return nil, err
+	}
+
+	t := client.Topic(topicID)
+
+	exists, err := t.Exists(ctx)
 
 	if err != nil {
 		log.WithError(err).Error("error checking if topic exists")
@@ -51,28 +191,143 @@ func (ps *pubSubImpl) ensureTopic(ctx context.Context, topicName string) (*gcps.
 	}
 
 	if !exists {
-		topic, err = ps.client.CreateTopic(ctx, topicName)
+		t, err = client.CreateTopicWithConfig(ctx, topicID, createTopicConfig(topic, schemaSettings))
+
+		if err != nil {
+			log.WithError(err).Error("error creating topic")
+			return nil, err
+		}
+
+		log.Debug("topic created")
+	} else if update {
+		current, err := t.Config(ctx)
+
+		if err != nil {
+			log.WithError(err).Error("error getting topic config")
+			return nil, err
+		}
+
+		if config, ok := updateTopicConfig(current, topic, schemaSettings); ok {
+			if _, err = t.Update(ctx, config); err != nil {
+				log.WithError(err).Error("error updating topic")
+				return nil, err
+			}
+
+			log.Debug("topic updated")
+		}
+	}
+
+	return t, nil
+}
+
+func (ps *pubSubImpl) CreateTopic(ctx context.Context, topic Topic) error {
+	_, err := ps.ensureTopic(ctx, topic, true)
+
+	return err
+}
+
+func (ps *pubSubImpl) DeleteTopic(ctx context.Context, topic Topic) error {
+	projectID, topicID := topic.Resolve(ps.projectID)
+
+	log := ps.log.WithField("topic", topicID).WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
 	}
 
+	t := client.Topic(topicID)
+
+	exists, err := t.Exists(ctx)
+
 	if err != nil {
-		log.WithError(err).Error("error creating topic")
-		return nil, err
+		log.WithError(err).Error("error checking if topic exists")
+		return err
 	}
 
-	return topic, nil
+	if !exists {
+		log.Debug("skipping non-existing topic")
+		return nil
+	}
+
+	config, err := t.Config(ctx)
+
+	if err != nil {
+		log.WithError(err).Error("error getting topic config")
+		return err
+	}
+
+	// topics created by others, e.g. by a producer, are never deleted
+	if !IsManaged(config.Labels) {
+		log.Debug("skipping topic not managed by lacuna")
+		return nil
+	}
+
+	// subscriptions keeping their backlog outlive their container, and must
+	// not lose their topic to another container releasing it
+	backlog, err := ps.hasBacklogSubscriptions(ctx, t)
+
+	if err != nil {
+		log.WithError(err).Error("error listing topic subscriptions")
+		return err
+	}
+
+	if backlog {
+		log.Debug("skipping topic with subscriptions keeping their backlog")
+		return nil
+	}
+
+	if err = t.Delete(ctx); err != nil {
+		log.WithError(err).Error("error removing topic")
+		return err
+	}
+
+	log.Debug("topic removed")
+
+	return nil
 }
 
 func (ps *pubSubImpl) CreateSubscription(ctx context.Context, subscription Subscription) error {
-	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint)
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint).WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
 
-	topic, err := ps.ensureTopic(ctx, subscription.Topic)
+	topic, err := ps.ensureTopic(ctx, subscription.GetTopic(), false)
 
 	if err != nil {
 		log.WithError(err).Error("error ensuring topic")
 		return err
 	}
 
-	sub := ps.client.Subscription(subscription.GetSubscriptionID())
+	var deadLetterTopic *gcps.Topic
+
+	if subscription.DeadLetterTopic != "" {
+		deadLetterTopic, err = ps.ensureTopic(ctx, subscription.GetDeadLetterTopic(), false)
+
+		if err != nil {
+			log.WithError(err).Error("error ensuring dead-letter topic")
+			return err
+		}
+
+		if subscription.DeadLetterSubscription {
+			if err := ps.ensureDeadLetterSubscription(ctx, client, deadLetterTopic, subscription); err != nil {
+				return err
+			}
+		}
+	}
+
+	config := createSubscriptionConfig(topic, deadLetterTopic, subscription)
+
+	sub := client.Subscription(subscription.GetSubscriptionID())
 
 	exists, err := sub.Exists(ctx)
 
@@ -81,21 +336,44 @@ func (ps *pubSubImpl) CreateSubscription(ctx context.Context, subscription Subsc
 		return err
 	}
 
-	// re-create subscription if it already exists.
-	// TODO: evaluate, maybe we should just update the subscription instead?
-	// however, updating a subscription does not update all of the properties
-	if exists {
-		sub.Delete(ctx)
-		// return ps.updateSubscription(ctx, sub, subscription)
+	if !exists {
+		return ps.createSubscription(ctx, client, config, subscription)
+	}
+
+	current, err := sub.Config(ctx)
+
+	if err != nil {
+		log.WithError(err).Error("error getting subscription config")
+		return err
+	}
+
+	diff := diffSubscriptionConfig(current, config)
+
+	if !diff.changed() {
+		log.Debug("subscription up to date")
+		return nil
+	}
+
+	// update the subscription in place to keep its backlog, unless
+	// an immutable field changed which requires re-creating it
+	if diff.recreate == "" {
+		return ps.updateSubscription(ctx, sub, diff, subscription)
+	}
+
+	log.WithField("reason", diff.recreate).Info("re-creating subscription")
+
+	if err := sub.Delete(ctx); err != nil {
+		log.WithError(err).Error("error removing subscription")
+		return err
 	}
 
-	return ps.createSubscription(ctx, topic, subscription)
+	return ps.createSubscription(ctx, client, config, subscription)
 }
 
-func (ps *pubSubImpl) createSubscription(ctx context.Context, topic *gcps.Topic, subscription Subscription) error {
+func (ps *pubSubImpl) createSubscription(ctx context.Context, client *gcps.Client, config gcps.SubscriptionConfig, subscription Subscription) error {
 	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint)
 
-	_, err := ps.client.CreateSubscription(ctx, subscription.GetSubscriptionID(), createSubscriptionConfig(topic, subscription))
+	_, err := client.CreateSubscription(ctx, subscription.GetSubscriptionID(), config)
 
 	if err != nil {
 		log.WithError(err).Error("error creating subscription")
@@ -107,25 +385,106 @@ func (ps *pubSubImpl) createSubscription(ctx context.Context, topic *gcps.Topic,
 	return nil
 }
 
-// func (ps *pubSubImpl) updateSubscription(ctx context.Context, sub *gcps.Subscription, subscription Subscription) error {
-// 	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint)
+func (ps *pubSubImpl) updateSubscription(ctx context.Context, sub *gcps.Subscription, diff subscriptionDiff, subscription Subscription) error {
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint)
 
-// 	_, err := sub.Update(ctx, updateSubscriptionConfig(subscription))
+	_, err := sub.Update(ctx, diff.update)
 
-// 	if err != nil {
-// 		log.WithError(err).Error("error updating subscription")
-// 		return err
-// 	}
+	if err != nil {
+		log.WithError(err).Error("error updating subscription")
+		return err
+	}
 
-// 	log.Debug("subscription updated")
+	log.WithField("fields", diff.fields).Debug("subscription updated")
 
-// 	return nil
-// }
+	return nil
+}
 
 func (ps *pubSubImpl) DeleteSubscription(ctx context.Context, subscription Subscription) error {
-	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint)
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint).WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
+
+	if err := ps.deleteSubscription(ctx, log, client, subscription.GetSubscriptionID()); err != nil {
+		return err
+	}
+
+	if subscription.DeadLetterTopic != "" && subscription.DeadLetterSubscription {
+		log := log.WithField("subscription_id", subscription.GetDeadLetterSubscriptionID()).WithField("topic", subscription.DeadLetterTopic)
+
+		return ps.deleteSubscription(ctx, log, client, subscription.GetDeadLetterSubscriptionID())
+	}
+
+	return nil
+}
+
+// SuspendSubscription detaches the push endpoint of a subscription, so it keeps
+// accumulating messages without delivering them. Recreating the subscription
+// attaches the endpoint again, and the backlog is delivered. Subscriptions
+// keeping their backlog are labelled with the time they were suspended.
+func (ps *pubSubImpl) SuspendSubscription(ctx context.Context, subscription Subscription) error {
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("topic", subscription.Topic).WithField("endpoint", subscription.Endpoint).WithField("project", projectID)
+
+	if !subscription.IsPush() && !subscription.KeepBacklog {
+		log.Debug("skipping pull subscription, nothing to detach")
+		return nil
+	}
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
 
-	sub := ps.client.Subscription(subscription.GetSubscriptionID())
+	sub := client.Subscription(subscription.GetSubscriptionID())
+
+	exists, err := sub.Exists(ctx)
+
+	if err != nil {
+		log.WithError(err).Error("error checking if subscription exists")
+		return err
+	}
+
+	if !exists {
+		log.Debug("skipping non-existing subscription")
+		return nil
+	}
+
+	var update gcps.SubscriptionConfigToUpdate
+
+	// an empty push config turns the subscription into a pull subscription
+	if subscription.IsPush() {
+		update.PushConfig = &gcps.PushConfig{}
+	}
+
+	// subscriptions keeping their backlog are marked with the time they were
+	// suspended, as they are only kept as orphans until the backlog ttl expired
+	if subscription.KeepBacklog {
+		update.Labels = suspendedLabels(subscriptionLabels(subscription), time.Now())
+	}
+
+	if _, err := sub.Update(ctx, update); err != nil {
+		log.WithError(err).Error("error detaching push endpoint")
+		return err
+	}
+
+	log.Debug("subscription suspended")
+
+	return nil
+}
+
+func (ps *pubSubImpl) deleteSubscription(ctx context.Context, log *log.Entry, client *gcps.Client, subscriptionID string) error {
+	sub := client.Subscription(subscriptionID)
 
 	exists, err := sub.Exists(ctx)
 
@@ -151,12 +510,234 @@ func (ps *pubSubImpl) DeleteSubscription(ctx context.Context, subscription Subsc
 	return nil
 }
 
-func createSubscriptionConfig(topic *gcps.Topic, subscription Subscription) gcps.SubscriptionConfig {
+// hasBacklogSubscriptions reports whether any subscription attached
+// to the topic is keeping its backlog, which has not expired yet.
+func (ps *pubSubImpl) hasBacklogSubscriptions(ctx context.Context, t *gcps.Topic) (bool, error) {
+	it := t.Subscriptions(ctx)
+
+	for {
+		sub, err := it.Next()
+
+		if err == iterator.Done {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		config, err := sub.Config(ctx)
+
+		if status.Code(err) == codes.NotFound {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if keepsBacklog(config.Labels) && !backlogExpired(config.Labels, ps.backlogTTL) {
+			return true, nil
+		}
+	}
+}
+
+// DeleteOrphanedSubscriptions deletes all subscriptions managed by lacuna
+// which belong to a container that is no longer running, e.g. because it
+// stopped while lacuna was not running.
+func (ps *pubSubImpl) DeleteOrphanedSubscriptions(ctx context.Context, containerIDs []string) error {
+	// look for orphans in all configured projects, and all projects used so far
+	projects := map[string]bool{ps.projectID: true}
+
+	for _, projectID := range append(ps.projects, ps.clients.projects()...) {
+		projects[projectID] = true
+	}
+
+	for projectID := range projects {
+		client, err := ps.clients.client(projectID)
+
+		if err != nil {
+			ps.log.WithError(err).WithField("project", projectID).Error("error creating client")
+			return err
+		}
+
+		if err := ps.deleteOrphanedSubscriptions(ctx, client, containerIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ps *pubSubImpl) deleteOrphanedSubscriptions(ctx context.Context, client *gcps.Client, containerIDs []string) error {
+	it := client.Subscriptions(ctx)
+
+	for {
+		config, err := it.NextConfig()
+
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			ps.log.WithError(err).WithField("project", client.Project()).Error("error listing subscriptions")
+			return err
+		}
+
+		// temporary subscriptions are cleaned up by the process that created them
+		if !IsManaged(config.Labels) || isTail(config.Labels) || isOwnedByAny(config.Labels, containerIDs) {
+			continue
+		}
+
+		log := ps.log.WithField("subscription_id", config.ID()).WithField("container_id", config.Labels[LABEL_CONTAINER]).WithField("project", client.Project())
+
+		// subscriptions keeping their backlog outlive their container, until
+		// they were suspended for longer than the backlog ttl
+		if keepsBacklog(config.Labels) && !backlogExpired(config.Labels, ps.backlogTTL) {
+			// containers stopped while lacuna was not running were never
+			// suspended, so the ttl starts once they are found instead
+			if _, ok := suspendedAt(config.Labels); !ok {
+				update := gcps.SubscriptionConfigToUpdate{Labels: suspendedLabels(config.Labels, time.Now())}
+
+				if _, err := client.Subscription(config.ID()).Update(ctx, update); err != nil {
+					log.WithError(err).Error("error marking subscription as suspended")
+					return err
+				}
+			}
+
+			log.Debug("keeping backlog of orphaned subscription")
+			continue
+		}
+
+		if err := ps.deleteSubscription(ctx, log, client, config.ID()); err != nil {
+			return err
+		}
+
+		log.Info("orphaned subscription removed")
+	}
+
+	return nil
+}
+
+// existingSubscription returns the subscription with the given ID,
+// or an error if it does not exist.
+func (ps *pubSubImpl) existingSubscription(ctx context.Context, projectID string, subscriptionID string) (*gcps.Subscription, error) {
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sub := client.Subscription(subscriptionID)
+
+	exists, err := sub.Exists(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("subscription %s does not exist in project %s", subscriptionID, projectID)
+	}
+
+	return sub, nil
+}
+
+// ensureDeadLetterSubscription creates a pull subscription on the dead-letter
+// topic, so messages which exhausted their delivery attempts can be inspected.
+func (ps *pubSubImpl) ensureDeadLetterSubscription(ctx context.Context, client *gcps.Client, deadLetterTopic *gcps.Topic, subscription Subscription) error {
+	log := ps.log.WithField("subscription_id", subscription.GetDeadLetterSubscriptionID()).WithField("topic", subscription.DeadLetterTopic)
+
+	sub := client.Subscription(subscription.GetDeadLetterSubscriptionID())
+
+	exists, err := sub.Exists(ctx)
+
+	if err != nil {
+		log.WithError(err).Error("error checking if subscription exists")
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	_, err = client.CreateSubscription(ctx, subscription.GetDeadLetterSubscriptionID(), gcps.SubscriptionConfig{
+		Topic:  deadLetterTopic,
+		Labels: subscription.Labels,
+	})
+
+	if err != nil {
+		log.WithError(err).Error("error creating dead-letter subscription")
+		return err
+	}
+
+	log.Debug("dead-letter subscription created")
+
+	return nil
+}
+
+func createTopicConfig(topic Topic, schemaSettings *gcps.SchemaSettings) *gcps.TopicConfig {
+	config := &gcps.TopicConfig{
+		Labels:         topic.Labels,
+		SchemaSettings: schemaSettings,
+	}
+
+	if topic.RetentionDuration > 0 {
+		config.RetentionDuration = topic.RetentionDuration
+	}
+
+	return config
+}
+
+// updateTopicConfig returns the settings declared by the topic which differ from
+// the current config of the existing topic, and false if none of them changed.
+func updateTopicConfig(current gcps.TopicConfig, topic Topic, schemaSettings *gcps.SchemaSettings) (gcps.TopicConfigToUpdate, bool) {
+	var config gcps.TopicConfigToUpdate
+
+	if schemaSettings != nil && !schemaSettingsEqual(current.SchemaSettings, schemaSettings) {
+		config.SchemaSettings = schemaSettings
+	}
+
+	if topic.Labels != nil && !labelsEqual(current.Labels, topic.Labels) {
+		config.Labels = topic.Labels
+	}
+
+	if topic.RetentionDuration > 0 && optionalDuration(current.RetentionDuration) != topic.RetentionDuration {
+		config.RetentionDuration = topic.RetentionDuration
+	}
+
+	return config, config.Labels != nil || config.RetentionDuration != nil || config.SchemaSettings != nil
+}
+
+func mapSchemaType(schemaType SchemaType) gcps.SchemaType {
+	switch schemaType {
+	case SCHEMA_TYPE_AVRO:
+		return gcps.SchemaAvro
+	case SCHEMA_TYPE_PROTOBUF:
+		return gcps.SchemaProtocolBuffer
+	default:
+		return gcps.SchemaTypeUnspecified
+	}
+}
+
+func mapSchemaEncoding(encoding SchemaEncoding) gcps.SchemaEncoding {
+	switch encoding {
+	case SCHEMA_ENCODING_JSON:
+		return gcps.EncodingJSON
+	case SCHEMA_ENCODING_BINARY:
+		return gcps.EncodingBinary
+	default:
+		return gcps.EncodingUnspecified
+	}
+}
+
+func createSubscriptionConfig(topic *gcps.Topic, deadLetterTopic *gcps.Topic, subscription Subscription) gcps.SubscriptionConfig {
 	var deadLetterPolicy *gcps.DeadLetterPolicy
 
-	if subscription.DeadLetterTopic != "" {
+	// dead-letter policies require the fully qualified topic name
+	if deadLetterTopic != nil {
 		deadLetterPolicy = &gcps.DeadLetterPolicy{
-			DeadLetterTopic:     subscription.DeadLetterTopic,
+			DeadLetterTopic:     deadLetterTopic.String(),
 			MaxDeliveryAttempts: subscription.MaxDeadLetterDeliveryAttempts,
 		}
 	}
@@ -169,11 +750,42 @@ func createSubscriptionConfig(topic *gcps.Topic, subscription Subscription) gcps
 		retryPolicy.MaximumBackoff = *subscription.RetryMaximumBackoff
 	}
 
+	// pull subscriptions are created with an empty push config
+	var pushConfig gcps.PushConfig
+
+	if subscription.IsPush() {
+		pushConfig = gcps.PushConfig{
+			Endpoint:   subscription.GetPushEndpoint(),
+			Attributes: subscription.PushAttributes,
+		}
+
+		// deliver the raw message body instead of the json envelope
+		if subscription.PushNoWrapper {
+			pushConfig.Wrapper = &gcps.NoWrapper{
+				WriteMetadata: subscription.PushWriteMetadata,
+			}
+		}
+
+		// attach an oidc token to push requests, as pub/sub does in production
+		if subscription.OIDCServiceAccountEmail != "" {
+			audience := subscription.OIDCAudience
+
+			// the audience defaults to the push endpoint, which
+			// must not be the proxy relaying the requests
+			if audience == "" && subscription.ProxyEndpoint != "" {
+				audience = subscription.Endpoint
+			}
+
+			pushConfig.AuthenticationMethod = &gcps.OIDCToken{
+				ServiceAccountEmail: subscription.OIDCServiceAccountEmail,
+				Audience:            audience,
+			}
+		}
+	}
+
 	return gcps.SubscriptionConfig{
-		Topic: topic,
-		PushConfig: gcps.PushConfig{
-			Endpoint: subscription.Endpoint,
-		},
+		Topic:                     topic,
+		PushConfig:                pushConfig,
 		AckDeadline:               subscription.AckDeadline,
 		RetainAckedMessages:       subscription.RetainAckedMessages,
 		RetentionDuration:         subscription.RetentionDuration,
@@ -183,37 +795,24 @@ func createSubscriptionConfig(topic *gcps.Topic, subscription Subscription) gcps
 		EnableExactlyOnceDelivery: subscription.DeliverExactlyOnce,
 		DeadLetterPolicy:          deadLetterPolicy,
 		RetryPolicy:               retryPolicy,
+		Labels:                    subscriptionLabels(subscription),
 	}
 }
 
-// func updateSubscriptionConfig(subscription Subscription) gcps.SubscriptionConfigToUpdate {
-// 	var deadLetterPolicy *gcps.DeadLetterPolicy
-
-// 	if subscription.DeadLetterTopic != "" {
-// 		deadLetterPolicy = &gcps.DeadLetterPolicy{
-// 			DeadLetterTopic:     subscription.DeadLetterTopic,
-// 			MaxDeliveryAttempts: subscription.MaxDeadLetterDeliveryAttempts,
-// 		}
-// 	}
-
-// 	retryPolicy := &gcps.RetryPolicy{}
-// 	if subscription.RetryMinimumBackoff != nil {
-// 		retryPolicy.MinimumBackoff = *subscription.RetryMinimumBackoff
-// 	}
-// 	if subscription.RetryMaximumBackoff != nil {
-// 		retryPolicy.MaximumBackoff = *subscription.RetryMaximumBackoff
-// 	}
-
-// 	return gcps.SubscriptionConfigToUpdate{
-// 		PushConfig: &gcps.PushConfig{
-// 			Endpoint: subscription.Endpoint,
-// 		},
-// 		AckDeadline:               subscription.AckDeadline,
-// 		RetainAckedMessages:       subscription.RetainAckedMessages,
-// 		RetentionDuration:         subscription.RetentionDuration,
-// 		ExpirationPolicy:          subscription.ExpirationTTL,
-// 		EnableExactlyOnceDelivery: subscription.DeliverExactlyOnce,
-// 		DeadLetterPolicy:          deadLetterPolicy,
-// 		RetryPolicy:               retryPolicy,
-// 	}
-// }
+// subscriptionLabels returns the labels of the subscription, marking
+// subscriptions keeping their backlog, so they are not removed as orphans.
+func subscriptionLabels(subscription Subscription) map[string]string {
+	if !subscription.KeepBacklog {
+		return subscription.Labels
+	}
+
+	labels := make(map[string]string, len(subscription.Labels)+1)
+
+	for key, value := range subscription.Labels {
+		labels[key] = value
+	}
+
+	labels[LABEL_KEEP_BACKLOG] = "true"
+
+	return labels
+}
@@ -3,8 +3,53 @@ package pubsub
 import (
 	"context"
 	"testing"
+	"time"
+
+	gcps "cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// newFakePubSub returns a pubsub backed by an in-memory pub/sub server.
+func newFakePubSub(t *testing.T) *pubSubImpl {
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := grpc.Dial(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := gcps.NewClient(context.Background(), "test", option.WithGRPCConn(conn))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { client.Close() })
+
+	return NewPubSubWithClient(client, nil).(*pubSubImpl)
+}
+
+func topicExists(t *testing.T, ps *pubSubImpl, topicID string) bool {
+	client, err := ps.clients.client(ps.projectID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := client.Topic(topicID).Exists(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
 func TestNewPubSubReturnsClient(t *testing.T) {
 	// arrange
 	ctx := context.Background()
@@ -17,3 +62,525 @@ func TestNewPubSubReturnsClient(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCreateSubscriptionConfigSetsPushEndpoint(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "test",
+		Endpoint: "http://test/messages",
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	if config.PushConfig.Endpoint != "http://test/messages" {
+		t.Errorf("expected push endpoint to be 'http://test/messages', got '%s'", config.PushConfig.Endpoint)
+	}
+}
+
+func TestCreateSubscriptionConfigOmitsPushConfigForPullSubscription(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:  SUBSCRIPTION_TYPE_PULL,
+		Topic: "test",
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	if config.PushConfig.Endpoint != "" {
+		t.Errorf("expected push endpoint to be empty, got '%s'", config.PushConfig.Endpoint)
+	}
+}
+
+func TestCreateSubscriptionConfigSetsOIDCToken(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:                    SUBSCRIPTION_TYPE_PUSH,
+		Topic:                   "test",
+		Endpoint:                "http://test/messages",
+		OIDCServiceAccountEmail: "push@project.iam.gserviceaccount.com",
+		OIDCAudience:            "https://test",
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	token, ok := config.PushConfig.AuthenticationMethod.(*gcps.OIDCToken)
+
+	if !ok {
+		t.Fatalf("expected authentication method to be an oidc token, got %T", config.PushConfig.AuthenticationMethod)
+	}
+
+	if token.ServiceAccountEmail != "push@project.iam.gserviceaccount.com" {
+		t.Errorf("expected service account email to be 'push@project.iam.gserviceaccount.com', got '%s'", token.ServiceAccountEmail)
+	}
+
+	if token.Audience != "https://test" {
+		t.Errorf("expected audience to be 'https://test', got '%s'", token.Audience)
+	}
+}
+
+func TestCreateSubscriptionConfigSetsNoWrapper(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:              SUBSCRIPTION_TYPE_PUSH,
+		Topic:             "test",
+		Endpoint:          "http://test/messages",
+		PushNoWrapper:     true,
+		PushWriteMetadata: true,
+		PushAttributes:    map[string]string{"x-goog-version": "v1"},
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	wrapper, ok := config.PushConfig.Wrapper.(*gcps.NoWrapper)
+
+	if !ok {
+		t.Fatalf("expected wrapper to be no wrapper, got %T", config.PushConfig.Wrapper)
+	}
+
+	if !wrapper.WriteMetadata {
+		t.Errorf("expected write metadata to be true")
+	}
+
+	if config.PushConfig.Attributes["x-goog-version"] != "v1" {
+		t.Errorf("expected attribute x-goog-version to be 'v1', got '%s'", config.PushConfig.Attributes["x-goog-version"])
+	}
+}
+
+func TestCreateTopicConfigSetsSchemaSettings(t *testing.T) {
+	// arrange
+	topic := Topic{
+		Name:              "test",
+		RetentionDuration: time.Hour,
+	}
+	schemaSettings := &gcps.SchemaSettings{
+		Schema:   "projects/test/schemas/test",
+		Encoding: mapSchemaEncoding(SCHEMA_ENCODING_JSON),
+	}
+
+	// act
+	config := createTopicConfig(topic, schemaSettings)
+
+	// assert
+	if config.SchemaSettings.Schema != "projects/test/schemas/test" {
+		t.Errorf("expected schema to be 'projects/test/schemas/test', got '%s'", config.SchemaSettings.Schema)
+	}
+
+	if config.SchemaSettings.Encoding != gcps.EncodingJSON {
+		t.Errorf("expected encoding to be json, got '%d'", config.SchemaSettings.Encoding)
+	}
+
+	if config.RetentionDuration != time.Hour {
+		t.Errorf("expected retention duration to be 1h, got '%v'", config.RetentionDuration)
+	}
+}
+
+func TestUpdateTopicConfigSkipsTopicWithoutSettings(t *testing.T) {
+	// act
+	_, ok := updateTopicConfig(gcps.TopicConfig{}, Topic{Name: "test"}, nil)
+
+	// assert
+	if ok {
+		t.Errorf("expected topic without settings not to be updated")
+	}
+}
+
+func TestUpdateTopicConfigSkipsUnchangedSchemaSettings(t *testing.T) {
+	// arrange
+	schemaSettings := &gcps.SchemaSettings{Schema: "projects/test/schemas/order", Encoding: gcps.EncodingJSON}
+	current := gcps.TopicConfig{
+		SchemaSettings: &gcps.SchemaSettings{Schema: "projects/test/schemas/order", Encoding: gcps.EncodingJSON},
+	}
+
+	// act
+	_, ok := updateTopicConfig(current, Topic{Name: "test", Schema: "order"}, schemaSettings)
+
+	// assert
+	if ok {
+		t.Errorf("expected topic with unchanged schema settings not to be updated")
+	}
+}
+
+func TestUpdateTopicConfigUpdatesChangedSchemaSettings(t *testing.T) {
+	// arrange
+	schemaSettings := &gcps.SchemaSettings{Schema: "projects/test/schemas/order", Encoding: gcps.EncodingBinary}
+	current := gcps.TopicConfig{
+		SchemaSettings:    &gcps.SchemaSettings{Schema: "projects/test/schemas/order", Encoding: gcps.EncodingJSON},
+		RetentionDuration: time.Hour,
+	}
+
+	// act
+	config, ok := updateTopicConfig(current, Topic{Name: "test", Schema: "order", RetentionDuration: time.Hour}, schemaSettings)
+
+	// assert
+	if !ok || config.SchemaSettings != schemaSettings {
+		t.Errorf("expected changed schema settings to be updated, got %+v", config)
+	}
+
+	if config.RetentionDuration != nil {
+		t.Errorf("expected unchanged retention duration not to be updated, got %v", config.RetentionDuration)
+	}
+}
+
+func TestCreateSubscriptionConfigUsesQualifiedDeadLetterTopic(t *testing.T) {
+	// arrange
+	client, err := gcps.NewClient(context.Background(), "test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription := Subscription{
+		Type:                          SUBSCRIPTION_TYPE_PUSH,
+		Topic:                         "test",
+		Endpoint:                      "http://test/messages",
+		DeadLetterTopic:               "dead-letter",
+		MaxDeadLetterDeliveryAttempts: 10,
+	}
+
+	// act
+	config := createSubscriptionConfig(client.Topic("test"), client.Topic("dead-letter"), subscription)
+
+	// assert
+	if config.DeadLetterPolicy == nil {
+		t.Fatalf("expected dead-letter policy to be set")
+	}
+
+	if config.DeadLetterPolicy.DeadLetterTopic != "projects/test/topics/dead-letter" {
+		t.Errorf("expected dead-letter topic to be 'projects/test/topics/dead-letter', got '%s'", config.DeadLetterPolicy.DeadLetterTopic)
+	}
+
+	if config.DeadLetterPolicy.MaxDeliveryAttempts != 10 {
+		t.Errorf("expected max delivery attempts to be 10, got %d", config.DeadLetterPolicy.MaxDeliveryAttempts)
+	}
+}
+
+func TestCreateSubscriptionConfigUsesProxyEndpoint(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:                    SUBSCRIPTION_TYPE_PUSH,
+		Topic:                   "test",
+		Endpoint:                "http://test/messages",
+		ProxyEndpoint:           "http://lacuna:8090/projects/test/subscriptions/test",
+		OIDCServiceAccountEmail: "push@project.iam.gserviceaccount.com",
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	if config.PushConfig.Endpoint != "http://lacuna:8090/projects/test/subscriptions/test" {
+		t.Errorf("expected push endpoint to be the proxy, got '%s'", config.PushConfig.Endpoint)
+	}
+
+	token, ok := config.PushConfig.AuthenticationMethod.(*gcps.OIDCToken)
+
+	if !ok {
+		t.Fatalf("expected authentication method to be an oidc token, got %T", config.PushConfig.AuthenticationMethod)
+	}
+
+	if token.Audience != "http://test/messages" {
+		t.Errorf("expected audience to be 'http://test/messages', got '%s'", token.Audience)
+	}
+}
+
+func TestCreateSubscriptionConfigMarksSubscriptionKeepingBacklog(t *testing.T) {
+	// arrange
+	subscription := Subscription{
+		Type:        SUBSCRIPTION_TYPE_PUSH,
+		Topic:       "test",
+		Endpoint:    "http://test/messages",
+		KeepBacklog: true,
+		Labels:      OwnershipLabels("1", ""),
+	}
+
+	// act
+	config := createSubscriptionConfig(nil, nil, subscription)
+
+	// assert
+	if !keepsBacklog(config.Labels) {
+		t.Errorf("expected subscription to be marked as keeping its backlog, got %v", config.Labels)
+	}
+
+	if !IsManaged(config.Labels) {
+		t.Errorf("expected ownership labels to be kept, got %v", config.Labels)
+	}
+
+	if _, ok := subscription.Labels[LABEL_KEEP_BACKLOG]; ok {
+		t.Errorf("expected labels of the subscription not to be modified")
+	}
+}
+
+func TestDeleteTopicKeepsTopicOfSubscriptionKeepingBacklog(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+
+	// container a keeps the backlog of its subscription while it is stopped
+	a := Subscription{
+		Service:     "a",
+		Name:        "orders",
+		Type:        SUBSCRIPTION_TYPE_PUSH,
+		Topic:       "orders",
+		Endpoint:    "http://a/messages",
+		KeepBacklog: true,
+		Labels:      OwnershipLabels("a", ""),
+	}
+
+	// container b subscribes to the same topic once a stopped
+	b := Subscription{
+		Service:  "b",
+		Name:     "orders",
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "orders",
+		Endpoint: "http://b/messages",
+		Labels:   OwnershipLabels("b", ""),
+	}
+
+	for _, err := range []error{
+		ps.CreateSubscription(ctx, a),
+		ps.SuspendSubscription(ctx, a),
+		ps.CreateSubscription(ctx, b),
+		ps.DeleteSubscription(ctx, b),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// act
+	err := ps.DeleteTopic(ctx, Topic{Name: "orders"})
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !topicExists(t, ps, "orders") {
+		t.Errorf("expected topic of subscription keeping its backlog to be kept")
+	}
+}
+
+func TestDeleteTopicDeletesTopicWithoutBacklogSubscriptions(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+
+	subscription := Subscription{
+		Service:  "b",
+		Name:     "orders",
+		Type:     SUBSCRIPTION_TYPE_PUSH,
+		Topic:    "orders",
+		Endpoint: "http://b/messages",
+		Labels:   OwnershipLabels("b", ""),
+	}
+
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err := ps.DeleteTopic(ctx, Topic{Name: "orders"})
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if topicExists(t, ps, "orders") {
+		t.Errorf("expected topic to be deleted")
+	}
+}
+
+func TestDeleteTopicKeepsUnmanagedTopic(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+
+	client, err := ps.clients.client(ps.projectID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// created by a producer, not by lacuna
+	if _, err := client.CreateTopic(ctx, "orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err = ps.DeleteTopic(ctx, Topic{Name: "orders"})
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !topicExists(t, ps, "orders") {
+		t.Errorf("expected unmanaged topic to be kept")
+	}
+}
+
+func newBacklogTestSubscription() Subscription {
+	return Subscription{
+		Service:     "a",
+		Name:        "orders",
+		Type:        SUBSCRIPTION_TYPE_PUSH,
+		Topic:       "orders",
+		Endpoint:    "http://a/messages",
+		KeepBacklog: true,
+		Labels:      OwnershipLabels("a", ""),
+	}
+}
+
+func subscriptionExists(t *testing.T, ps *pubSubImpl, subscriptionID string) bool {
+	client, err := ps.clients.client(ps.projectID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := client.Subscription(subscriptionID).Exists(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+func TestDeleteOrphanedSubscriptionsKeepsSuspendedBacklog(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+	subscription := newBacklogTestSubscription()
+
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ps.SuspendSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err := ps.DeleteOrphanedSubscriptions(ctx, nil)
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !subscriptionExists(t, ps, subscription.GetSubscriptionID()) {
+		t.Errorf("expected subscription keeping its backlog to be kept")
+	}
+}
+
+func TestDeleteOrphanedSubscriptionsDeletesExpiredBacklog(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+	ps.backlogTTL = time.Hour
+	subscription := newBacklogTestSubscription()
+
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := ps.clients.client(ps.projectID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// suspended before the backlog ttl
+	labels := suspendedLabels(subscriptionLabels(subscription), time.Now().Add(-2*time.Hour))
+
+	if _, err := client.Subscription(subscription.GetSubscriptionID()).Update(ctx, gcps.SubscriptionConfigToUpdate{Labels: labels}); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err = ps.DeleteOrphanedSubscriptions(ctx, nil)
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subscriptionExists(t, ps, subscription.GetSubscriptionID()) {
+		t.Errorf("expected subscription with expired backlog to be deleted")
+	}
+}
+
+func TestDeleteOrphanedSubscriptionsMarksBacklogOfUnsuspendedSubscription(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+	subscription := newBacklogTestSubscription()
+
+	// the container stopped while lacuna was not running
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err := ps.DeleteOrphanedSubscriptions(ctx, nil)
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := ps.clients.client(ps.projectID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := client.Subscription(subscription.GetSubscriptionID()).Config(ctx)
+
+	if err != nil {
+		t.Fatalf("expected subscription keeping its backlog to be kept, got %v", err)
+	}
+
+	if _, ok := suspendedAt(config.Labels); !ok {
+		t.Errorf("expected subscription to be marked as suspended, got %v", config.Labels)
+	}
+}
+
+func TestDeleteTopicDeletesTopicOfExpiredBacklog(t *testing.T) {
+	// arrange
+	ctx := context.Background()
+	ps := newFakePubSub(t)
+	ps.backlogTTL = 0
+	subscription := newBacklogTestSubscription()
+
+	if err := ps.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ps.SuspendSubscription(ctx, subscription); err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err := ps.DeleteTopic(ctx, Topic{Name: "orders"})
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if topicExists(t, ps, "orders") {
+		t.Errorf("expected topic of expired backlog to be deleted")
+	}
+}
@@ -0,0 +1,183 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	gcps "cloud.google.com/go/pubsub"
+	"google.golang.org/api/iterator"
+)
+
+type Snapshot struct {
+	Name       string
+	Project    string
+	Topic      string
+	Expiration time.Time
+	Labels     map[string]string
+}
+
+// ParseSeekTime parses the seek target as a duration before now,
+// or as an RFC 3339 timestamp. Any other value is a snapshot name.
+func ParseSeekTime(value string, now time.Time) (time.Time, bool) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration.Abs()), true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+func (ps *pubSubImpl) SeekToTime(ctx context.Context, subscription Subscription, to time.Time) error {
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("project", projectID).WithField("time", to)
+
+	sub, err := ps.existingSubscription(ctx, projectID, subscription.GetSubscriptionID())
+
+	if err != nil {
+		log.WithError(err).Error("error getting subscription")
+		return err
+	}
+
+	if err := sub.SeekToTime(ctx, to); err != nil {
+		log.WithError(err).Error("error seeking subscription")
+		return err
+	}
+
+	log.Debug("subscription seeked to time")
+
+	return nil
+}
+
+func (ps *pubSubImpl) SeekToSnapshot(ctx context.Context, subscription Subscription, snapshot string) error {
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("project", projectID).WithField("snapshot", snapshot)
+
+	sub, err := ps.existingSubscription(ctx, projectID, subscription.GetSubscriptionID())
+
+	if err != nil {
+		log.WithError(err).Error("error getting subscription")
+		return err
+	}
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
+
+	if err := sub.SeekToSnapshot(ctx, client.Snapshot(snapshot)); err != nil {
+		log.WithError(err).Error("error seeking subscription")
+		return err
+	}
+
+	log.Debug("subscription seeked to snapshot")
+
+	return nil
+}
+
+func (ps *pubSubImpl) CreateSnapshot(ctx context.Context, subscription Subscription, snapshot string) (*Snapshot, error) {
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("project", projectID).WithField("snapshot", snapshot)
+
+	sub, err := ps.existingSubscription(ctx, projectID, subscription.GetSubscriptionID())
+
+	if err != nil {
+		log.WithError(err).Error("error getting subscription")
+		return nil, err
+	}
+
+	config, err := sub.CreateSnapshot(ctx, snapshot)
+
+	if err != nil {
+		log.WithError(err).Error("error creating snapshot")
+		return nil, err
+	}
+
+	log.Debug("snapshot created")
+
+	return mapSnapshot(config, projectID), nil
+}
+
+func (ps *pubSubImpl) ListSnapshots(ctx context.Context, projectID string) ([]Snapshot, error) {
+	if projectID == "" {
+		projectID = ps.projectID
+	}
+
+	log := ps.log.WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0)
+
+	it := client.Snapshots(ctx)
+
+	for {
+		config, err := it.Next()
+
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			log.WithError(err).Error("error listing snapshots")
+			return nil, err
+		}
+
+		snapshots = append(snapshots, *mapSnapshot(config, projectID))
+	}
+
+	return snapshots, nil
+}
+
+func (ps *pubSubImpl) DeleteSnapshot(ctx context.Context, snapshot Snapshot) error {
+	projectID := snapshot.Project
+
+	if projectID == "" {
+		projectID = ps.projectID
+	}
+
+	log := ps.log.WithField("snapshot", snapshot.Name).WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
+
+	if err := client.Snapshot(snapshot.Name).Delete(ctx); err != nil {
+		log.WithError(err).Error("error removing snapshot")
+		return err
+	}
+
+	log.Debug("snapshot removed")
+
+	return nil
+}
+
+func mapSnapshot(config *gcps.SnapshotConfig, projectID string) *Snapshot {
+	snapshot := &Snapshot{
+		Name:       config.ID(),
+		Project:    projectID,
+		Expiration: config.Expiration,
+		Labels:     config.Labels,
+	}
+
+	if config.Topic != nil {
+		snapshot.Topic = config.Topic.ID()
+	}
+
+	return snapshot
+}
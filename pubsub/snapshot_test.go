@@ -0,0 +1,41 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSeekTime(t *testing.T) {
+	// arrange
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		time  time.Time
+		ok    bool
+	}{
+		{"1h", now.Add(-time.Hour), true},
+		{"-30m", now.Add(-30 * time.Minute), true},
+		{"0s", now, true},
+		{"2023-05-31T08:30:00Z", time.Date(2023, 5, 31, 8, 30, 0, 0, time.UTC), true},
+		{"2023-05-31T10:30:00+02:00", time.Date(2023, 5, 31, 8, 30, 0, 0, time.UTC), true},
+		{"before-release", time.Time{}, false},
+		{"2023-05-31", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		// act
+		to, ok := ParseSeekTime(test.value, now)
+
+		// assert
+		if ok != test.ok {
+			t.Errorf("expected '%s' to be parsed as time: %t, got %t", test.value, test.ok, ok)
+			continue
+		}
+
+		if !to.Equal(test.time) {
+			t.Errorf("expected '%s' to be parsed as %s, got %s", test.value, test.time, to)
+		}
+	}
+}
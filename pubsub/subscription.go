@@ -5,11 +5,26 @@ import (
 	"time"
 )
 
+type SubscriptionType string
+
+const (
+	SUBSCRIPTION_TYPE_PUSH SubscriptionType = "push"
+	SUBSCRIPTION_TYPE_PULL SubscriptionType = "pull"
+)
+
+// Endpoint of subscriptions whose messages are captured by lacuna
+// instead of being pushed to a container
+const CAPTURE_ENDPOINT = "lacuna://capture"
+
 type Subscription struct {
 	Service                       string
 	Name                          string
+	Project                       string
+	Type                          SubscriptionType
 	Topic                         string
 	Endpoint                      string
+	EndpointPort                  int
+	EndpointNetwork               string
 	AckDeadline                   time.Duration
 	RetainAckedMessages           bool
 	RetentionDuration             time.Duration
@@ -18,11 +33,76 @@ type Subscription struct {
 	Filter                        string
 	DeliverExactlyOnce            bool
 	DeadLetterTopic               string
+	DeadLetterSubscription        bool
 	MaxDeadLetterDeliveryAttempts int
 	RetryMinimumBackoff           *time.Duration
 	RetryMaximumBackoff           *time.Duration
+	OIDCServiceAccountEmail       string
+	OIDCAudience                  string
+	PushNoWrapper                 bool
+	PushWriteMetadata             bool
+	PushAttributes                map[string]string
+	KeepBacklog                   bool
+	Proxy                         bool
+	ProxyEndpoint                 string
+	Labels                        map[string]string
 }
 
 func (s *Subscription) GetSubscriptionID() string {
 	return strings.Join([]string{s.Service, s.Name}, "_")
 }
+
+// GetDeadLetterSubscriptionID returns the ID of the pull subscription
+// used to inspect messages published to the dead-letter topic.
+func (s *Subscription) GetDeadLetterSubscriptionID() string {
+	return strings.Join([]string{s.GetSubscriptionID(), "dead-letter"}, "_")
+}
+
+// GetProject returns the project the subscription is created in.
+func (s *Subscription) GetProject(defaultProjectID string) string {
+	if s.Project != "" {
+		return s.Project
+	}
+
+	return defaultProjectID
+}
+
+// GetTopic returns the topic subscribed to, which is located in the
+// subscription's project unless its name is fully qualified.
+func (s *Subscription) GetTopic() Topic {
+	return Topic{Name: s.Topic, Project: s.Project, Labels: s.Labels}
+}
+
+// GetDeadLetterTopic returns the dead-letter topic of the subscription,
+// which is located in the subscription's project unless its name is
+// fully qualified.
+func (s *Subscription) GetDeadLetterTopic() Topic {
+	return Topic{Name: s.DeadLetterTopic, Project: s.Project, Labels: s.Labels}
+}
+
+// GetPushEndpoint returns the endpoint push requests are sent to, which
+// is the proxy relaying requests to the subscription's endpoint, if set.
+func (s *Subscription) GetPushEndpoint() string {
+	if s.ProxyEndpoint != "" {
+		return s.ProxyEndpoint
+	}
+
+	return s.Endpoint
+}
+
+// HasEndpointPath reports whether the endpoint is a path, whose
+// host is resolved from the networks and ports of the container.
+func (s *Subscription) HasEndpointPath() bool {
+	return strings.HasPrefix(s.Endpoint, "/")
+}
+
+// IsCapture reports whether messages are captured by lacuna.
+func (s *Subscription) IsCapture() bool {
+	return s.IsPush() && s.Endpoint == CAPTURE_ENDPOINT
+}
+
+// IsPush reports whether messages are pushed to the subscription's endpoint.
+// Subscriptions without an explicit type are treated as push subscriptions.
+func (s *Subscription) IsPush() bool {
+	return s.Type != SUBSCRIPTION_TYPE_PULL
+}
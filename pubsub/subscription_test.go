@@ -14,3 +14,16 @@ func TestGetSubscriptionId(t *testing.T) {
 		t.Errorf("Expected subscriptionId to be 'payment_product-created, got %s", subscriptionId)
 	}
 }
+
+func TestGetDeadLetterSubscriptionId(t *testing.T) {
+	subscription := Subscription{
+		Service: "payment",
+		Name:    "product-created",
+	}
+
+	subscriptionId := subscription.GetDeadLetterSubscriptionID()
+
+	if subscriptionId != "payment_product-created_dead-letter" {
+		t.Errorf("Expected subscriptionId to be 'payment_product-created_dead-letter, got %s", subscriptionId)
+	}
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	gcps "cloud.google.com/go/pubsub"
+	"google.golang.org/api/iterator"
+)
+
+// Temporary subscriptions expire once they were inactive for this long, in case
+// the tailing process could not delete them, which is the minimum pub/sub allows.
+const TAIL_SUBSCRIPTION_EXPIRATION = 24 * time.Hour
+
+// TailSubscription returns the temporary pull subscription used to tail a topic.
+// It is labelled with the host and process ID of the tailing process, so it can
+// be cleaned up if the process did not get the chance to delete it. The project
+// of the topic is part of the name, as topics in different projects may share
+// their ID.
+func TailSubscription(topic Topic, host string, pid int) Subscription {
+	projectID, topicID := topic.Resolve("")
+
+	if projectID != "" {
+		topicID = projectID + "_" + topicID
+	}
+
+	host = sanitizeLabelValue(host)
+
+	return Subscription{
+		Service: "lacuna-tail-" + host + "-" + strconv.Itoa(pid),
+		Name:    topicID,
+		Project: topic.Project,
+		Type:    SUBSCRIPTION_TYPE_PULL,
+		Topic:   topic.Name,
+		// expire subscriptions left behind by processes on other hosts
+		ExpirationTTL: TAIL_SUBSCRIPTION_EXPIRATION,
+		Labels: map[string]string{
+			LABEL_MANAGED_BY: MANAGED_BY_LACUNA,
+			LABEL_TAIL_HOST:  host,
+			LABEL_TAIL_PID:   strconv.Itoa(pid),
+		},
+	}
+}
+
+// Receive pulls messages from the subscription and acknowledges them once they
+// were passed to the handler, until the context is done. The handler may be
+// called concurrently.
+func (ps *pubSubImpl) Receive(ctx context.Context, subscription Subscription, handler func(Message)) error {
+	projectID := subscription.GetProject(ps.projectID)
+
+	log := ps.log.WithField("subscription_id", subscription.GetSubscriptionID()).WithField("project", projectID)
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		log.WithError(err).Error("error creating client")
+		return err
+	}
+
+	sub := client.Subscription(subscription.GetSubscriptionID())
+
+	err = sub.Receive(ctx, func(ctx context.Context, msg *gcps.Message) {
+		handler(mapMessage(msg))
+		msg.Ack()
+	})
+
+	if err != nil {
+		log.WithError(err).Error("error receiving messages")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStaleTailSubscriptions deletes the temporary subscriptions of tail
+// processes on the given host which are no longer alive, e.g. because they
+// crashed before removing their subscription.
+func (ps *pubSubImpl) DeleteStaleTailSubscriptions(ctx context.Context, projectID string, host string, alive func(pid int) bool) error {
+	if projectID == "" {
+		projectID = ps.projectID
+	}
+
+	client, err := ps.clients.client(projectID)
+
+	if err != nil {
+		ps.log.WithError(err).WithField("project", projectID).Error("error creating client")
+		return err
+	}
+
+	it := client.Subscriptions(ctx)
+
+	for {
+		config, err := it.NextConfig()
+
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			ps.log.WithError(err).WithField("project", projectID).Error("error listing subscriptions")
+			return err
+		}
+
+		if !IsManaged(config.Labels) || !isTail(config.Labels) || config.Labels[LABEL_TAIL_HOST] != sanitizeLabelValue(host) {
+			continue
+		}
+
+		if pid, err := strconv.Atoi(config.Labels[LABEL_TAIL_PID]); err == nil && alive(pid) {
+			continue
+		}
+
+		log := ps.log.WithField("subscription_id", config.ID()).WithField("project", projectID)
+
+		if err := ps.deleteSubscription(ctx, log, client, config.ID()); err != nil {
+			return err
+		}
+
+		log.Info("stale tail subscription removed")
+	}
+
+	return nil
+}
+
+func mapMessage(msg *gcps.Message) Message {
+	return Message{
+		ID:          msg.ID,
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+		PublishTime: msg.PublishTime,
+	}
+}
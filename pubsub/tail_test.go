@@ -0,0 +1,54 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTailSubscriptionIsLabelledPullSubscription(t *testing.T) {
+	// arrange
+	topic := Topic{Name: "projects/platform/topics/orders", Project: "other"}
+
+	// act
+	subscription := TailSubscription(topic, "My.Host", 42)
+
+	// assert
+	if subscription.GetSubscriptionID() != "lacuna-tail-my-host-42_platform_orders" {
+		t.Errorf("expected subscription ID to be 'lacuna-tail-my-host-42_platform_orders', got '%s'", subscription.GetSubscriptionID())
+	}
+
+	if subscription.IsPush() {
+		t.Errorf("expected tail subscription to be a pull subscription")
+	}
+
+	if subscription.Topic != "projects/platform/topics/orders" {
+		t.Errorf("expected topic to be 'projects/platform/topics/orders', got '%s'", subscription.Topic)
+	}
+
+	if !IsManaged(subscription.Labels) || !isTail(subscription.Labels) {
+		t.Errorf("expected tail subscription to be labelled, got %v", subscription.Labels)
+	}
+
+	if subscription.Labels[LABEL_TAIL_PID] != "42" {
+		t.Errorf("expected pid label to be '42', got '%s'", subscription.Labels[LABEL_TAIL_PID])
+	}
+
+	if subscription.ExpirationTTL < 24*time.Hour {
+		t.Errorf("expected tail subscription to expire after at least 24h, got %s", subscription.ExpirationTTL)
+	}
+}
+
+func TestTailSubscriptionsOfTopicsInDifferentProjectsDiffer(t *testing.T) {
+	// arrange
+	orders := Topic{Name: "projects/platform/topics/orders"}
+	otherOrders := Topic{Name: "orders", Project: "other"}
+
+	// act
+	subscription := TailSubscription(orders, "host", 42)
+	otherSubscription := TailSubscription(otherOrders, "host", 42)
+
+	// assert
+	if subscription.GetSubscriptionID() == otherSubscription.GetSubscriptionID() {
+		t.Errorf("expected subscription IDs to differ, got '%s'", subscription.GetSubscriptionID())
+	}
+}
@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type SchemaType string
+
+const (
+	SCHEMA_TYPE_AVRO     SchemaType = "avro"
+	SCHEMA_TYPE_PROTOBUF SchemaType = "protobuf"
+)
+
+type SchemaEncoding string
+
+const (
+	SCHEMA_ENCODING_JSON   SchemaEncoding = "json"
+	SCHEMA_ENCODING_BINARY SchemaEncoding = "binary"
+)
+
+type Topic struct {
+	Name              string
+	Project           string
+	RetentionDuration time.Duration
+	Labels            map[string]string
+	Retain            bool
+	Schema            string
+	SchemaFile        string
+	SchemaType        SchemaType
+	SchemaEncoding    SchemaEncoding
+}
+
+// HasSchema reports whether messages published to the topic
+// are validated against a schema.
+func (t *Topic) HasSchema() bool {
+	return t.SchemaFile != ""
+}
+
+// Resolve returns the project and ID of the topic. Fully qualified topic
+// names take precedence over the topic's project, which in turn defaults
+// to the given project.
+func (t *Topic) Resolve(defaultProjectID string) (string, string) {
+	if projectID, topicID, ok := ParseTopicName(t.Name); ok {
+		return projectID, topicID
+	}
+
+	if t.Project != "" {
+		return t.Project, t.Name
+	}
+
+	return defaultProjectID, t.Name
+}
+
+// QualifiedName returns the fully qualified name of the topic.
+func (t *Topic) QualifiedName(defaultProjectID string) string {
+	return QualifiedTopicName(t.Resolve(defaultProjectID))
+}
+
+// ParseTopicName splits a fully qualified topic name in the format
+// 'projects/<project>/topics/<topic>' into its project and topic ID.
+func ParseTopicName(name string) (string, string, bool) {
+	parts := strings.Split(name, "/")
+
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+		return "", "", false
+	}
+
+	return parts[1], parts[3], true
+}
+
+func QualifiedTopicName(projectID string, topicID string) string {
+	return fmt.Sprintf("projects/%s/topics/%s", projectID, topicID)
+}
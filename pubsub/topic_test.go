@@ -0,0 +1,28 @@
+package pubsub
+
+import "testing"
+
+func TestTopicResolvesQualifiedName(t *testing.T) {
+	// arrange
+	qualified := Topic{Name: "projects/platform/topics/events", Project: "other"}
+	project := Topic{Name: "events", Project: "platform"}
+	fallback := Topic{Name: "events"}
+
+	// act
+	qualifiedName := qualified.QualifiedName("test")
+	projectName := project.QualifiedName("test")
+	fallbackName := fallback.QualifiedName("test")
+
+	// assert
+	if qualifiedName != "projects/platform/topics/events" {
+		t.Errorf("expected qualified topic name to be 'projects/platform/topics/events', got '%s'", qualifiedName)
+	}
+
+	if projectName != "projects/platform/topics/events" {
+		t.Errorf("expected topic name in project to be 'projects/platform/topics/events', got '%s'", projectName)
+	}
+
+	if fallbackName != "projects/test/topics/events" {
+		t.Errorf("expected topic name in default project to be 'projects/test/topics/events', got '%s'", fallbackName)
+	}
+}